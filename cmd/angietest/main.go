@@ -0,0 +1,45 @@
+// Command angietest runs a fake Angie API for local development, so
+// "make rundev" can be used without a real Angie.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/melroy89/angie_telegraf_plugin/plugins/inputs/angie_api/angietest"
+)
+
+var listen = flag.String("listen", "localhost:8080", "address to serve the fake API on")
+var advanceInterval = flag.Duration("advance_interval", time.Second, "how often the counters advance")
+var reloadInterval = flag.Duration("reload_interval", 0, "how often to simulate a reload, 0 disables reloads")
+
+func main() {
+	flag.Parse()
+
+	server := angietest.NewServer(angietest.DefaultConfig())
+
+	go func() {
+		advance := time.NewTicker(*advanceInterval)
+		var reload <-chan time.Time
+		if *reloadInterval > 0 {
+			reload = time.NewTicker(*reloadInterval).C
+		}
+		for {
+			select {
+			case <-advance.C:
+				server.Advance()
+			case <-reload:
+				server.Reload()
+			}
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving fake Angie API on http://%s/status\n", *listen)
+	if err := http.ListenAndServe(*listen, server); err != nil {
+		fmt.Fprintf(os.Stderr, "Err: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -3,14 +3,23 @@ package angie_api
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
 	common_http "github.com/influxdata/telegraf/plugins/common/http"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
@@ -22,9 +31,31 @@ const sampleConfig = `
 
 const (
 	// Default settings
-	defaultAPIVersion = 1
+	defaultAPIVersion            = 1
+	defaultRetryBackoff          = config.Duration(100 * time.Millisecond)
+	defaultRetryMaxBackoff       = config.Duration(2 * time.Second)
+	defaultCircuitBreakerTimeout = config.Duration(time.Minute)
+	resolveTimeout               = 5 * time.Second
+
+	// Fetch modes
+	fetchModePaths = "paths"
+	fetchModeTree  = "tree"
+
+	// Response code fields
+	responseCodesEach  = "each"
+	responseCodesClass = "class"
+	responseCodesBoth  = "both"
+
+	// Prefix of regular expressions in zone, upstream and limit patterns
+	regexPatternPrefix = "re:"
+
+	// Sources of the source tag
+	sourceFromURL      = "url"
+	sourceFromHostname = "hostname"
+	sourceFromAddress  = "address"
 
 	// Paths
+	angiePath       = "angie"
 	processesPath   = "processes"
 	connectionsPath = "connections"
 	slabsPath       = "slabs"
@@ -43,64 +74,486 @@ const (
 )
 
 type AngieAPI struct {
-	Urls       []string        `toml:"urls"`
-	APIVersion int64           `toml:"api_version"`
-	Log        telegraf.Logger `toml:"-"`
+	Urls            []string  `toml:"urls"`
+	APIVersion      int64     `toml:"api_version"`
+	FetchMode       string    `toml:"fetch_mode"`
+	ResponseCodes   string    `toml:"response_codes"`
+	SourceFrom      string    `toml:"source_from"`
+	SchemaCheck     string    `toml:"schema_check"`
+	SectionsInclude []string  `toml:"sections_include"`
+	SectionsExclude []string  `toml:"sections_exclude"`
+	ZoneInclude     []string  `toml:"zone_include"`
+	ZoneExclude     []string  `toml:"zone_exclude"`
+	UpstreamInclude []string  `toml:"upstream_include"`
+	UpstreamExclude []string  `toml:"upstream_exclude"`
+	LimitInclude    []string  `toml:"limit_include"`
+	LimitExclude    []string  `toml:"limit_exclude"`
+	Targets         []*Target `toml:"target"`
+	TargetsFile     string    `toml:"targets_file"`
+	// Limits of concurrent gathers, 0 means no limit for the targets
+	MaxConcurrentTargets           int             `toml:"max_concurrent_targets"`
+	MaxConcurrentRequestsPerTarget int             `toml:"max_concurrent_requests_per_target"`
+	Retries                        int             `toml:"retries"`
+	RetryBackoff                   config.Duration `toml:"retry_backoff"`
+	RetryMaxBackoff                config.Duration `toml:"retry_max_backoff"`
+	CircuitBreakerFailures         int             `toml:"circuit_breaker_failures"`
+	CircuitBreakerTimeout          config.Duration `toml:"circuit_breaker_timeout"`
+	Log                            telegraf.Logger `toml:"-"`
+	common_http.HTTPClientConfig
+
+	client         *http.Client
+	hostname       string
+	sectionFilter  filter.Filter
+	zoneFilter     filter.Filter
+	upstreamFilter filter.Filter
+	limitFilter    filter.Filter
+
+	resolver resolver
+
+	mu      sync.Mutex
+	targets map[string]*target
+	file    targetsFile
+	// Targets of the instances of every DNS based URL as last resolved
+	resolved map[string][]*Target
+}
+
+// Target is an Angie API configured in its own [[inputs.angie_api.target]]
+// table, with its own HTTP client settings, credentials, sections and tags.
+type Target struct {
+	URL             string            `toml:"url"`
+	Source          string            `toml:"source"`
+	SourceFrom      string            `toml:"source_from"`
+	Username        config.Secret     `toml:"username"`
+	Password        config.Secret     `toml:"password"`
+	SectionsInclude []string          `toml:"sections_include"`
+	SectionsExclude []string          `toml:"sections_exclude"`
+	Tags            map[string]string `toml:"tags"`
 	common_http.HTTPClientConfig
 
 	client *http.Client
+	// nil if no sections are configured for the target, the sections of
+	// the plugin apply then
+	sectionFilter filter.Filter
+}
+
+// target holds the state kept for a single Angie API URL between Gather calls.
+type target struct {
+	// Settings of the [[inputs.angie_api.target]] table of the URL, nil
+	// for the URLs given in urls
+	config *Target
+
+	// Configuration generation and load time as last reported by Angie,
+	// used to detect reloads (which reset all counters).
+	generation int64
+	loadTime   string
+	// Address of the Angie instance as last reported by it, used as source
+	// tag with source_from = "address"
+	address string
+
+	// Sections found in the status tree by the discovery, nil if the
+	// discovery has not run (yet) since the last reload
+	available map[string]bool
+
+	// API version detected from the Angie version with api_version = 0,
+	// nil if not detected (yet) since the last reload
+	version *apiVersion
+
+	// Top-level objects of the status tree, only set during a gather
+	// in tree fetch mode
+	tree map[string]json.RawMessage
+
+	breaker breaker
+	// Schema mismatches last logged with schema_check = "warn"
+	schema schemaLog
+
+	// Statistics of the gather in progress, only set during a gather
+	scrape *scrape
 }
 
 func (*AngieAPI) SampleConfig() string {
 	return sampleConfig
 }
 
-func (n *AngieAPI) Gather(acc telegraf.Accumulator) error {
-	var wg sync.WaitGroup
+func (n *AngieAPI) Init() error {
+	switch n.FetchMode {
+	case "":
+		n.FetchMode = fetchModePaths
+	case fetchModePaths, fetchModeTree:
+	default:
+		return fmt.Errorf("invalid fetch_mode %q, expected %q or %q", n.FetchMode, fetchModePaths, fetchModeTree)
+	}
+
+	switch n.ResponseCodes {
+	case "":
+		n.ResponseCodes = responseCodesEach
+	case responseCodesEach, responseCodesClass, responseCodesBoth:
+	default:
+		return fmt.Errorf("invalid response_codes %q, expected %q, %q or %q",
+			n.ResponseCodes, responseCodesEach, responseCodesClass, responseCodesBoth)
+	}
+
+	switch n.SchemaCheck {
+	case "":
+		n.SchemaCheck = schemaCheckOff
+	case schemaCheckOff, schemaCheckWarn, schemaCheckStrict:
+	default:
+		return fmt.Errorf("invalid schema_check %q, expected %q, %q or %q",
+			n.SchemaCheck, schemaCheckOff, schemaCheckWarn, schemaCheckStrict)
+	}
+
+	if err := checkAPIVersion(n.APIVersion); err != nil {
+		return err
+	}
+
+	if n.MaxConcurrentTargets < 0 {
+		return fmt.Errorf("invalid max_concurrent_targets %d, expected 0 or more", n.MaxConcurrentTargets)
+	}
+	switch {
+	case n.MaxConcurrentRequestsPerTarget == 0:
+		n.MaxConcurrentRequestsPerTarget = 1
+	case n.MaxConcurrentRequestsPerTarget < 0:
+		return fmt.Errorf("invalid max_concurrent_requests_per_target %d, expected 1 or more",
+			n.MaxConcurrentRequestsPerTarget)
+	}
+
+	if n.Retries < 0 {
+		return fmt.Errorf("invalid retries %d, expected 0 or more", n.Retries)
+	}
+	if n.RetryBackoff <= 0 {
+		n.RetryBackoff = defaultRetryBackoff
+	}
+	if n.RetryMaxBackoff <= 0 {
+		n.RetryMaxBackoff = defaultRetryMaxBackoff
+	}
+	if n.CircuitBreakerFailures < 0 {
+		return fmt.Errorf("invalid circuit_breaker_failures %d, expected 0 or more", n.CircuitBreakerFailures)
+	}
+	if n.CircuitBreakerTimeout <= 0 {
+		n.CircuitBreakerTimeout = defaultCircuitBreakerTimeout
+	}
+
+	if err := checkSourceFrom(n.SourceFrom); err != nil {
+		return err
+	}
+	if n.SourceFrom == "" {
+		n.SourceFrom = sourceFromURL
+	}
+
+	var err error
+	if n.sectionFilter, err = newSectionFilter(n.SectionsInclude, n.SectionsExclude); err != nil {
+		return err
+	}
+	if n.zoneFilter, err = newNameFilter(n.ZoneInclude, n.ZoneExclude); err != nil {
+		return fmt.Errorf("creating zone filter failed: %w", err)
+	}
+	if n.upstreamFilter, err = newNameFilter(n.UpstreamInclude, n.UpstreamExclude); err != nil {
+		return fmt.Errorf("creating upstream filter failed: %w", err)
+	}
+	if n.limitFilter, err = newNameFilter(n.LimitInclude, n.LimitExclude); err != nil {
+		return fmt.Errorf("creating limit filter failed: %w", err)
+	}
+	// Target tables are checked with their own settings below
+	if len(n.Urls) > 0 || n.TargetsFile != "" {
+		if err := n.checkAddressSource(n.SourceFrom, n.sectionFilter); err != nil {
+			return err
+		}
+	}
+
+	n.targets = make(map[string]*target, len(n.Targets))
+	for i, cfg := range n.Targets {
+		if cfg.URL == "" {
+			return fmt.Errorf("url of target %d missing", i+1)
+		}
+		addr, err := parseAddress(cfg.URL)
+		if err != nil {
+			return fmt.Errorf("unable to parse address %q of target: %w", cfg.URL, err)
+		}
+		if _, ok := n.targets[addr.String()]; ok || n.inUrls(addr) {
+			return fmt.Errorf("target %q is configured more than once", cfg.URL)
+		}
+
+		if err := checkSourceFrom(cfg.SourceFrom); err != nil {
+			return fmt.Errorf("target %q: %w", cfg.URL, err)
+		}
+
+		if len(cfg.SectionsInclude) > 0 || len(cfg.SectionsExclude) > 0 {
+			if cfg.sectionFilter, err = newSectionFilter(cfg.SectionsInclude, cfg.SectionsExclude); err != nil {
+				return fmt.Errorf("target %q: %w", cfg.URL, err)
+			}
+		}
+		if cfg.Source == "" {
+			sourceFrom, sectionFilter := cfg.SourceFrom, cfg.sectionFilter
+			if sourceFrom == "" {
+				sourceFrom = n.SourceFrom
+			}
+			if sectionFilter == nil {
+				sectionFilter = n.sectionFilter
+			}
+			if err := n.checkAddressSource(sourceFrom, sectionFilter); err != nil {
+				return fmt.Errorf("target %q: %w", cfg.URL, err)
+			}
+		}
+
+		// Create the HTTP client of the target here, so invalid TLS settings
+		// fail the start instead of every gather
+		if cfg.client, err = n.createHTTPClient(&cfg.HTTPClientConfig); err != nil {
+			return fmt.Errorf("target %q: %w", cfg.URL, err)
+		}
+		n.targets[addr.String()] = &target{config: cfg}
+	}
+
+	if n.resolver == nil {
+		n.resolver = net.DefaultResolver
+	}
+
+	if n.TargetsFile != "" && !slices.Contains([]string{".json", ".toml"}, filepath.Ext(n.TargetsFile)) {
+		return fmt.Errorf("unknown format of targets file %q, expected a .json or .toml file", n.TargetsFile)
+	}
+
+	usesHostname := func(cfg *Target) bool { return cfg.SourceFrom == sourceFromHostname }
+	if n.SourceFrom == sourceFromHostname || slices.ContainsFunc(n.Targets, usesHostname) {
+		if n.hostname, err = os.Hostname(); err != nil {
+			return fmt.Errorf("getting hostname failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// inUrls reports whether the API is given in urls. URLs are compared in
+// their parsed form, so "unix://" and "http+unix://" URLs of the same socket
+// match.
+func (n *AngieAPI) inUrls(addr *url.URL) bool {
+	for _, u := range n.Urls {
+		if parsed, err := parseAddress(u); err == nil && parsed.String() == addr.String() {
+			return true
+		}
+	}
+	return false
+}
 
-	// Only support API version 1 (currently APIVersion is not yet used)
-	if n.APIVersion == 0 {
-		n.APIVersion = defaultAPIVersion
+func checkSourceFrom(sourceFrom string) error {
+	switch sourceFrom {
+	case "", sourceFromURL, sourceFromHostname, sourceFromAddress:
+		return nil
 	}
+	return fmt.Errorf("invalid source_from %q, expected %q, %q or %q",
+		sourceFrom, sourceFromURL, sourceFromHostname, sourceFromAddress)
+}
+
+// checkAddressSource makes sure the address reported by Angie can be used as
+// source tag. It is only read from the angie section, which is requested if it
+// is gathered or to detect the API version.
+func (n *AngieAPI) checkAddressSource(sourceFrom string, sectionFilter filter.Filter) error {
+	if sourceFrom == sourceFromAddress && !matches(sectionFilter, angiePath) && n.APIVersion != 0 {
+		return fmt.Errorf("source_from %q requires the %q section or api_version = 0", sourceFromAddress, angiePath)
+	}
+	return nil
+}
+
+// newSectionFilter creates the filter for the given section patterns. Every
+// pattern has to match at least one section, so typos in section names are
+// reported instead of silently matching nothing.
+func newSectionFilter(include, exclude []string) (filter.Filter, error) {
+	for _, pattern := range slices.Concat(include, exclude) {
+		f, err := filter.Compile([]string{pattern})
+		if err != nil {
+			return nil, fmt.Errorf("invalid section pattern %q: %w", pattern, err)
+		}
+		if !slices.ContainsFunc(sectionNames(), f.Match) {
+			return nil, fmt.Errorf("section pattern %q does not match any section", pattern)
+		}
+	}
+
+	f, err := filter.NewIncludeExcludeFilter(include, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("creating section filter failed: %w", err)
+	}
+	return f, nil
+}
+
+// namePatterns are the glob patterns and, given with the "re:" prefix,
+// regular expressions of a zone, upstream or limit filter.
+type namePatterns struct {
+	globs   filter.Filter
+	regexps []*regexp.Regexp
+}
+
+func compileNamePatterns(patterns []string) (*namePatterns, error) {
+	var globs []string
+	p := &namePatterns{}
+	for _, pattern := range patterns {
+		expr, ok := strings.CutPrefix(pattern, regexPatternPrefix)
+		if !ok {
+			globs = append(globs, pattern)
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		p.regexps = append(p.regexps, re)
+	}
+
+	var err error
+	if p.globs, err = filter.Compile(globs); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *namePatterns) empty() bool {
+	return p.globs == nil && len(p.regexps) == 0
+}
+
+func (p *namePatterns) match(name string) bool {
+	if p.globs != nil && p.globs.Match(name) {
+		return true
+	}
+	return slices.ContainsFunc(p.regexps, func(re *regexp.Regexp) bool { return re.MatchString(name) })
+}
+
+// nameFilter selects zones, upstreams or limits by their name.
+type nameFilter struct {
+	include *namePatterns
+	exclude *namePatterns
+}
+
+func newNameFilter(include, exclude []string) (filter.Filter, error) {
+	f := &nameFilter{}
+	var err error
+	if f.include, err = compileNamePatterns(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compileNamePatterns(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *nameFilter) Match(name string) bool {
+	if !f.include.empty() && !f.include.match(name) {
+		return false
+	}
+	return !f.exclude.match(name)
+}
+
+func (n *AngieAPI) Gather(acc telegraf.Accumulator) error {
+	var wg sync.WaitGroup
 
 	// Create an HTTP client that is re-used for each
 	// collection interval
 	if n.client == nil {
-		client, err := n.createHTTPClient()
+		client, err := n.createHTTPClient(&n.HTTPClientConfig)
 		if err != nil {
 			return err
 		}
 		n.client = client
 	}
 
-	for _, u := range n.Urls {
-		addr, err := url.Parse(u)
+	if n.TargetsFile != "" {
+		if err := n.loadTargetsFile(); err != nil {
+			acc.AddError(err)
+		}
+	}
+
+	urls := slices.Clone(n.Urls)
+	for _, cfg := range slices.Concat(n.Targets, n.file.targets) {
+		urls = append(urls, cfg.URL)
+	}
+
+	addrs := make([]*url.URL, 0, len(urls))
+	seen := make(map[string]bool, len(urls))
+	add := func(addr *url.URL) {
+		// The state of a target is shared by all URLs of the same API, like
+		// a "unix://" and "http+unix://" URL of one socket, so it must be
+		// gathered only once at a time
+		if !seen[addr.String()] {
+			seen[addr.String()] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	for _, u := range urls {
+		addr, err := parseAddress(u)
 		if err != nil {
 			acc.AddError(fmt.Errorf("unable to parse address %q: %w", u, err))
 			continue
 		}
 
+		// DNS based targets are resolved into their instances on every
+		// gather, as instances come and go
+		if isDNSAddress(addr) {
+			instances, err := n.resolveTargets(addr)
+			if err != nil {
+				acc.AddError(err)
+				continue
+			}
+			for _, instance := range instances {
+				add(instance)
+			}
+			continue
+		}
+		add(addr)
+	}
+
+	// Gather the targets by a pool of workers, so a large fleet does not
+	// open connections to all instances at once
+	workers := len(addrs)
+	if n.MaxConcurrentTargets > 0 {
+		workers = min(workers, n.MaxConcurrentTargets)
+	}
+	queue := make(chan *url.URL)
+	for range workers {
 		wg.Add(1)
-		go func(addr *url.URL) {
+		go func() {
 			defer wg.Done()
-			n.gatherMetrics(addr, acc)
-		}(addr)
+			for addr := range queue {
+				n.gatherMetrics(addr, acc)
+			}
+		}()
 	}
+	for _, addr := range addrs {
+		queue <- addr
+	}
+	close(queue)
 
 	wg.Wait()
 	return nil
 }
 
-func (n *AngieAPI) createHTTPClient() (*http.Client, error) {
-	if n.HTTPClientConfig.ResponseHeaderTimeout < config.Duration(time.Second) {
-		n.HTTPClientConfig.ResponseHeaderTimeout = config.Duration(time.Second * 5)
+// parseAddress parses an API URL. Unix socket URLs like
+// "unix:///run/angie/api.sock:/status" are turned into the "http+unix" scheme
+// handled by the transport of the HTTP client.
+func parseAddress(u string) (*url.URL, error) {
+	addr, err := url.Parse(u)
+	if err != nil {
+		return nil, err
 	}
 
-	n.Log.Debugf("Creating HTTP client with response timeout of %s", n.HTTPClientConfig.ResponseHeaderTimeout)
+	switch addr.Scheme {
+	case "unix":
+		addr.Scheme = "http+unix"
+	case "http+unix", "https+unix":
+	default:
+		return addr, nil
+	}
+
+	if socket, location, ok := strings.Cut(addr.Path, ":"); !ok || socket == "" || location == "" {
+		return nil, errors.New("expected a unix socket URL like unix:///run/angie/api.sock:/status")
+	}
+	return addr, nil
+}
+
+func (n *AngieAPI) createHTTPClient(cfg *common_http.HTTPClientConfig) (*http.Client, error) {
+	if cfg.ResponseHeaderTimeout < config.Duration(time.Second) {
+		cfg.ResponseHeaderTimeout = config.Duration(time.Second * 5)
+	}
+
+	n.Log.Debugf("Creating HTTP client with response timeout of %s", cfg.ResponseHeaderTimeout)
 
 	// Create the client
 	ctx := context.Background()
-	client, err := n.HTTPClientConfig.CreateClient(ctx, n.Log)
+	client, err := cfg.CreateClient(ctx, n.Log)
 	if err != nil {
 		return nil, fmt.Errorf("creating client failed: %w", err)
 	}
@@ -108,6 +561,37 @@ func (n *AngieAPI) createHTTPClient() (*http.Client, error) {
 	return client, nil
 }
 
+// sectionEnabled reports whether the section is gathered from the target,
+// using the sections of its target table if it has any.
+func (n *AngieAPI) sectionEnabled(t *target, path string) bool {
+	if t.config != nil && t.config.sectionFilter != nil {
+		return matches(t.config.sectionFilter, path)
+	}
+	return matches(n.sectionFilter, path)
+}
+
+// matches reports whether the name passes the filter, where a filter that was
+// not set up (yet) lets every name pass.
+func matches(f filter.Filter, name string) bool {
+	return f == nil || f.Match(name)
+}
+
+func (n *AngieAPI) target(addr *url.URL) *target {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.targets == nil {
+		n.targets = make(map[string]*target)
+	}
+
+	t, ok := n.targets[addr.String()]
+	if !ok {
+		t = &target{}
+		n.targets[addr.String()] = t
+	}
+	return t
+}
+
 func init() {
 	inputs.Add("angie_api", func() telegraf.Input {
 		return &AngieAPI{}
@@ -0,0 +1,119 @@
+package angie_api
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	// States of a circuit breaker
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+var (
+	// errBreakerOpen signals that a request was not made, because the
+	// circuit breaker of the target is open.
+	errBreakerOpen = errors.New("circuit breaker open")
+)
+
+// breaker is the circuit breaker of a target. It opens after a number of
+// consecutive failed requests, so an unreachable Angie is not requested on
+// every gather, and lets a probe through once the timeout has passed.
+type breaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// allow reports whether a request may be made to the target. Once the
+// timeout has passed, an open breaker switches to half open and lets a
+// single probe through, all other requests are refused until the outcome
+// of the probe is recorded.
+func (b *breaker) allow(now time.Time, timeout time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < timeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+	return true
+}
+
+// blocked reports whether the breaker is open and its timeout has not passed
+// yet, without letting a probe through.
+func (b *breaker) blocked(now time.Time, timeout time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == breakerOpen && now.Sub(b.openedAt) < timeout
+}
+
+// record counts the outcome of a request. A failed probe of a half open
+// breaker opens it again right away.
+func (b *breaker) record(failed bool, now time.Time, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.state != breakerOpen && b.failures >= threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+}
+
+func (b *breaker) status() (state string, failures int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == "" {
+		return breakerClosed, b.failures
+	}
+	return b.state, b.failures
+}
+
+func (n *AngieAPI) addBreakerMetrics(addr *url.URL, t *target, acc telegraf.Accumulator) {
+	state, failures := t.breaker.status()
+	fields := map[string]interface{}{
+		"state":    state,
+		"failures": int64(failures),
+	}
+	acc.AddFields("angie_api_circuit_breaker", fields, n.getTags(addr))
+}
+
+// retryBackoff returns the time to wait before the given retry (starting at
+// zero). It doubles with every retry up to retry_max_backoff, of which a
+// random part of up to one half is taken off, so targets failing at the same
+// time are not retried all at once.
+func (n *AngieAPI) retryBackoff(retry int) time.Duration {
+	maxBackoff := time.Duration(n.RetryMaxBackoff)
+	backoff := min(time.Duration(n.RetryBackoff), maxBackoff)
+	for range retry {
+		backoff = min(2*backoff, maxBackoff)
+	}
+	if backoff <= 1 {
+		return backoff
+	}
+
+	return backoff - rand.N(backoff/2)
+}
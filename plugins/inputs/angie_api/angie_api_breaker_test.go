@@ -0,0 +1,225 @@
+package angie_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/melroy89/angie_telegraf_plugin/plugins/inputs/angie_api/angietest"
+)
+
+// failingHandler answers the requests with the given status until it is
+// set to zero, after which it serves the fake Angie API.
+type failingHandler struct {
+	handler  http.Handler
+	status   atomic.Int32
+	requests atomic.Int32
+}
+
+func (h *failingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests.Add(1)
+	if status := h.status.Load(); status != 0 {
+		w.WriteHeader(int(status))
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// failFirst lets the first requests fail with the given status.
+func (h *failingHandler) failFirst(status, count int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(h.requests.Add(1)) <= count {
+			w.WriteHeader(status)
+			return
+		}
+		h.handler.ServeHTTP(w, r)
+	})
+}
+
+func TestGatherRetries(t *testing.T) {
+	h := &failingHandler{handler: angietest.NewServer(angietest.DefaultConfig())}
+	ts := httptest.NewServer(h.failFirst(http.StatusServiceUnavailable, 2))
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/status"},
+		APIVersion:      1,
+		SectionsInclude: []string{"connections"},
+		Retries:         2,
+		RetryBackoff:    config.Duration(time.Millisecond),
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+	require.True(t, acc.HasMeasurement("angie_api_connections"))
+	// Two failed and one successful discovery, one request for the section
+	require.Equal(t, int32(4), h.requests.Load())
+}
+
+func TestGatherRetriesOnlyTransientErrors(t *testing.T) {
+	h := &failingHandler{handler: angietest.NewServer(angietest.DefaultConfig())}
+	h.status.Store(http.StatusForbidden)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/status"},
+		APIVersion:      1,
+		SectionsInclude: []string{"connections"},
+		Retries:         3,
+		RetryBackoff:    config.Duration(time.Millisecond),
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.ErrorContains(t, acc.FirstError(), "403 Forbidden")
+	// Discovery and section are requested once each
+	require.Equal(t, int32(2), h.requests.Load())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	h := &failingHandler{handler: angietest.NewServer(angietest.DefaultConfig())}
+	h.status.Store(http.StatusBadGateway)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:                   []string{ts.URL + "/status"},
+		SectionsInclude:        []string{"connections"},
+		CircuitBreakerFailures: 2,
+		CircuitBreakerTimeout:  config.Duration(time.Hour),
+		Log:                    testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	gather := func() (*testutil.Accumulator, string, int64) {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		state, ok := acc.StringField("angie_api_circuit_breaker", "state")
+		require.True(t, ok)
+		failures, ok := acc.Int64Field("angie_api_circuit_breaker", "failures")
+		require.True(t, ok)
+		return &acc, state, failures
+	}
+
+	// The failed discovery and section open the breaker
+	acc, state, failures := gather()
+	require.Len(t, acc.Errors, 2)
+	require.Equal(t, "open", state)
+	require.Equal(t, int64(2), failures)
+
+	// Open breakers skip the target
+	acc, state, _ = gather()
+	require.Empty(t, acc.Errors)
+	require.Equal(t, "open", state)
+	require.Equal(t, int32(2), h.requests.Load())
+
+	// After the timeout a successful probe closes the breaker again
+	n.CircuitBreakerTimeout = config.Duration(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	h.status.Store(0)
+	acc, state, failures = gather()
+	require.NoError(t, acc.FirstError())
+	require.True(t, acc.HasMeasurement("angie_api_connections"))
+	require.Equal(t, "closed", state)
+	require.Zero(t, failures)
+}
+
+func TestCircuitBreakerFailedProbe(t *testing.T) {
+	var b breaker
+	now := time.Now()
+
+	b.record(true, now, 1)
+	require.False(t, b.allow(now, time.Minute))
+
+	// A single failed probe opens the breaker again
+	now = now.Add(time.Minute)
+	require.True(t, b.allow(now, time.Minute))
+	b.record(true, now, 3)
+	state, failures := b.status()
+	require.Equal(t, breakerOpen, state)
+	require.Equal(t, 2, failures)
+	require.False(t, b.allow(now.Add(time.Second), time.Minute))
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	var b breaker
+	now := time.Now()
+
+	b.record(true, now, 1)
+	require.True(t, b.blocked(now, time.Minute))
+
+	// Only one probe is let through while half open
+	now = now.Add(time.Minute)
+	require.False(t, b.blocked(now, time.Minute))
+	require.True(t, b.allow(now, time.Minute))
+	require.False(t, b.allow(now, time.Minute))
+	require.False(t, b.allow(now.Add(time.Hour), time.Minute))
+
+	b.record(false, now, 1)
+	require.True(t, b.allow(now, time.Minute))
+	require.True(t, b.allow(now, time.Minute))
+}
+
+func TestGatherCircuitBreakerSingleProbe(t *testing.T) {
+	h := &failingHandler{handler: angietest.NewServer(angietest.DefaultConfig())}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:                           []string{ts.URL + "/status"},
+		APIVersion:                     1,
+		SectionsInclude:                []string{"connections", "slabs", "http/upstreams"},
+		MaxConcurrentRequestsPerTarget: 3,
+		CircuitBreakerFailures:         1,
+		CircuitBreakerTimeout:          config.Duration(time.Hour),
+		Log:                            testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	// Discover the sections, then let them fail to open the breaker
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+	h.status.Store(http.StatusBadGateway)
+	require.NoError(t, n.Gather(&acc))
+
+	// The sections are requested concurrently, but only one probe is made
+	n.CircuitBreakerTimeout = config.Duration(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	requests := h.requests.Load()
+	require.NoError(t, n.Gather(&acc))
+	require.Equal(t, requests+1, h.requests.Load())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	n := &AngieAPI{
+		RetryBackoff:    config.Duration(100 * time.Millisecond),
+		RetryMaxBackoff: config.Duration(time.Second),
+	}
+
+	for retry, expected := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		backoff := n.retryBackoff(retry)
+		require.LessOrEqual(t, backoff, expected, "retry %d", retry)
+		require.Greater(t, backoff, expected/2, "retry %d", retry)
+	}
+}
@@ -0,0 +1,113 @@
+package angie_api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// Classes of gather errors
+	errorClassNetwork     = "network"
+	errorClassHTTPStatus  = "http_status"
+	errorClassContentType = "content_type"
+	errorClassDecode      = "decode"
+	errorClassSchema      = "schema"
+	errorClassOther       = "other"
+)
+
+var (
+	// errNotFound signals that the Angie API path does not exist. It matches
+	// the errors of all 404 Not Found responses.
+	errNotFound = &gatherError{
+		class:  errorClassHTTPStatus,
+		status: http.StatusNotFound,
+		err:    errors.New("not found"),
+	}
+)
+
+// gatherError is a failure of gathering a section (an API path, or the API
+// root for an empty section) from a target, classified by its cause.
+type gatherError struct {
+	class   string
+	target  string
+	section string
+	// HTTP status of the response for http_status errors
+	status int
+	err    error
+}
+
+func newGatherError(class string, addr *url.URL, section string, err error) *gatherError {
+	return &gatherError{
+		class:   class,
+		target:  addr.String(),
+		section: section,
+		err:     err,
+	}
+}
+
+func (e *gatherError) Error() string {
+	if e.target == "" {
+		return e.err.Error()
+	}
+
+	section := e.section
+	if section == "" {
+		section = "/"
+	}
+	return fmt.Sprintf("gathering %q from %q failed with %s error: %v", section, e.target, e.class, e.err)
+}
+
+func (e *gatherError) Unwrap() error {
+	return e.err
+}
+
+// Is matches errors of the same class and, if set, HTTP status, so
+// errors.Is(err, errNotFound) holds for every 404 Not Found response.
+func (e *gatherError) Is(target error) bool {
+	t, ok := target.(*gatherError)
+	return ok && t.class == e.class && (t.status == 0 || t.status == e.status)
+}
+
+// transient reports whether the error may go away when trying again, as
+// for network errors and server errors (HTTP 5xx).
+func (e *gatherError) transient() bool {
+	return e.class == errorClassNetwork || e.class == errorClassHTTPStatus && e.status >= http.StatusInternalServerError
+}
+
+// isTransient reports whether the error is a transient gather error.
+func isTransient(err error) bool {
+	var gerr *gatherError
+	return errors.As(err, &gerr) && gerr.transient()
+}
+
+// errorClass returns the class of a gather error, or "other" for all other
+// errors.
+func errorClass(err error) string {
+	var gerr *gatherError
+	if errors.As(err, &gerr) {
+		return gerr.class
+	}
+	return errorClassOther
+}
+
+// decode decodes the JSON document of a section. Documents that are no valid
+// JSON are decode errors, documents not matching the expected types (e.g.
+// after an Angie upgrade) schema errors.
+func decode(addr *url.URL, section string, body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return decodeError(addr, section, err)
+	}
+	return nil
+}
+
+// decodeError classifies an error returned by json.Unmarshal.
+func decodeError(addr *url.URL, section string, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return newGatherError(errorClassSchema, addr, section, err)
+	}
+	return newGatherError(errorClassDecode, addr, section, err)
+}
@@ -0,0 +1,140 @@
+package angie_api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestGatherErrorClasses(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		class       string
+		transient   bool
+	}{
+		{
+			name:        "http status",
+			status:      http.StatusForbidden,
+			contentType: "text/plain",
+			class:       errorClassHTTPStatus,
+		},
+		{
+			name:        "server error",
+			status:      http.StatusServiceUnavailable,
+			contentType: "text/plain",
+			class:       errorClassHTTPStatus,
+			transient:   true,
+		},
+		{
+			name:        "content type",
+			status:      http.StatusOK,
+			contentType: "text/html",
+			body:        "<html></html>",
+			class:       errorClassContentType,
+		},
+		{
+			name:        "decode",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"accepted": `,
+			class:       errorClassDecode,
+		},
+		{
+			name:        "schema",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"accepted": "many"}`,
+			class:       errorClassSchema,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/"+connectionsPath {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer ts.Close()
+
+			n := &AngieAPI{
+				Urls:            []string{ts.URL + "/api"},
+				SectionsInclude: []string{"connections"},
+				Log:             testutil.Logger{},
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Len(t, acc.Errors, 1)
+
+			var gerr *gatherError
+			require.ErrorAs(t, acc.Errors[0], &gerr)
+			require.Equal(t, tt.class, gerr.class)
+			require.Equal(t, ts.URL+"/api", gerr.target)
+			require.Equal(t, connectionsPath, gerr.section)
+			require.Equal(t, tt.transient, gerr.transient())
+			require.ErrorContains(t, gerr, fmt.Sprintf("gathering %q from %q failed with %s error", connectionsPath, ts.URL+"/api", tt.class))
+
+			count, ok := acc.Int64Field("angie_api_scrape", "errors_"+tt.class)
+			require.True(t, ok)
+			require.Equal(t, int64(1), count)
+		})
+	}
+}
+
+func TestGatherNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/api"},
+		APIVersion:      1,
+		SectionsInclude: []string{"connections"},
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+
+	// The discovery fails, so the section is not requested
+	require.Len(t, acc.Errors, 1)
+	require.Equal(t, errorClassNetwork, errorClass(acc.Errors[0]))
+	require.True(t, isTransient(acc.Errors[0]))
+	count, ok := acc.Int64Field("angie_api_scrape", "errors_network")
+	require.True(t, ok)
+	require.Equal(t, int64(1), count)
+}
+
+func TestErrNotFound(t *testing.T) {
+	addr := &url.URL{Scheme: "http", Host: "localhost", Path: "/status"}
+
+	notFound := newGatherError(errorClassHTTPStatus, addr, httpCachesPath, errors.New("HTTP status 404 Not Found"))
+	notFound.status = http.StatusNotFound
+	require.ErrorIs(t, notFound, errNotFound)
+	require.ErrorIs(t, fmt.Errorf("wrapped: %w", notFound), errNotFound)
+	require.ErrorIs(t, errNotFound, errNotFound)
+
+	forbidden := newGatherError(errorClassHTTPStatus, addr, httpCachesPath, errors.New("HTTP status 403 Forbidden"))
+	forbidden.status = http.StatusForbidden
+	require.NotErrorIs(t, forbidden, errNotFound)
+
+	// Errors of a class can be matched regardless of the HTTP status
+	require.ErrorIs(t, forbidden, &gatherError{class: errorClassHTTPStatus})
+	require.NotErrorIs(t, forbidden, &gatherError{class: errorClassDecode})
+}
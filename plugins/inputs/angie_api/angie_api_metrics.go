@@ -8,113 +8,469 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
 )
 
-var (
-	// errNotFound signals that the Angie API routes does not exist.
-	errNotFound = errors.New("not found")
-)
+// section is a part of the Angie status tree together with the type its
+// JSON document is decoded into and the function adding its metrics.
+type section struct {
+	path string
+	typ  reflect.Type
+	add  func(n *AngieAPI, addr *url.URL, v interface{}, acc telegraf.Accumulator)
+}
+
+// newSection creates a section whose JSON document is decoded into T, which
+// is passed on to the function adding its metrics.
+func newSection[T any](path string, add func(*AngieAPI, *url.URL, T, telegraf.Accumulator)) section {
+	return section{
+		path: path,
+		typ:  reflect.TypeFor[T](),
+		add: func(n *AngieAPI, addr *url.URL, v interface{}, acc telegraf.Accumulator) {
+			add(n, addr, *v.(*T), acc)
+		},
+	}
+}
+
+// gather requests the section from the target, decodes it into a new value
+// of its type and adds its metrics.
+func (s section) gather(n *AngieAPI, addr *url.URL, acc telegraf.Accumulator) error {
+	body, err := n.gatherPath(addr, s.path)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.New(s.typ).Interface()
+	if err := decode(addr, s.path, body, v); err != nil {
+		return err
+	}
+	s.add(n, addr, v, acc)
+
+	return nil
+}
+
+// sectionsV1 are the sections of API version 1, besides the angie section
+// which is provided by every version.
+var sectionsV1 = []section{
+	newSection(processesPath, (*AngieAPI).addProcessesMetrics),
+	newSection(connectionsPath, (*AngieAPI).addConnectionsMetrics),
+	newSection(slabsPath, (*AngieAPI).addSlabsMetrics),
+	newSection(httpServerZonesPath, (*AngieAPI).addHTTPServerZonesMetrics),
+	newSection(httpUpstreamsPath, (*AngieAPI).addHTTPUpstreamsMetrics),
+	newSection(httpCachesPath, (*AngieAPI).addHTTPCachesMetrics),
+	newSection(httpLocationZonesPath, (*AngieAPI).addHTTPLocationZonesMetrics),
+	newSection(resolverZonesPath, (*AngieAPI).addResolverZonesMetrics),
+	newSection(httpLimitReqsPath, (*AngieAPI).addHTTPLimitReqsMetrics),
+	newSection(httpLimitConnsPath, (*AngieAPI).addHTTPLimitConnsMetrics),
+	newSection(streamServerZonesPath, (*AngieAPI).addStreamServerZonesMetrics),
+	newSection(streamUpstreamsPath, (*AngieAPI).addStreamUpstreamsMetrics),
+	newSection(streamLimitConnsPath, (*AngieAPI).addStreamLimitConnsMetrics),
+}
 
 func (n *AngieAPI) gatherMetrics(addr *url.URL, acc telegraf.Accumulator) {
-	addError(acc, n.gatherProcessesMetrics(addr, acc))
-	addError(acc, n.gatherConnectionsMetrics(addr, acc))
-	addError(acc, n.gatherSlabsMetrics(addr, acc))
-	addError(acc, n.gatherHTTPServerZonesMetrics(addr, acc))
-	addError(acc, n.gatherHTTPUpstreamsMetrics(addr, acc))
-	addError(acc, n.gatherHTTPCachesMetrics(addr, acc))
-	addError(acc, n.gatherHTTPLocationZonesMetrics(addr, acc))
-	addError(acc, n.gatherResolverZonesMetrics(addr, acc))
-	addError(acc, n.gatherHTTPLimitReqsMetrics(addr, acc))
-	addError(acc, n.gatherHTTPLimitConnsMetrics(addr, acc))
-	addError(acc, n.gatherStreamServerZonesMetrics(addr, acc))
-	addError(acc, n.gatherStreamUpstreamsMetrics(addr, acc))
-	addError(acc, n.gatherStreamLimitConnsMetrics(addr, acc))
+	t := n.target(addr)
+	if t.config != nil && len(t.config.Tags) > 0 {
+		acc = &tagsAccumulator{Accumulator: acc, tags: t.config.Tags}
+	}
+
+	// Report how the gather went, also when the target is skipped
+	t.scrape = newScrape()
+	defer func(s *scrape, acc telegraf.Accumulator) {
+		t.scrape = nil
+		n.addScrapeMetrics(addr, s, acc)
+	}(t.scrape, acc)
+
+	if n.CircuitBreakerFailures > 0 {
+		defer n.addBreakerMetrics(addr, t, acc)
+		if t.breaker.blocked(time.Now(), time.Duration(n.CircuitBreakerTimeout)) {
+			n.Log.Debugf("Circuit breaker of %q is open, skipping it", addr.String())
+			return
+		}
+	}
+
+	if n.FetchMode == fetchModeTree {
+		// Download the whole status tree at once, the sections below
+		// are then taken from it instead of being requested one by one
+		if err := n.gatherTree(addr, t); err != nil {
+			t.scrape.failed(err)
+			acc.AddError(err)
+			return
+		}
+		defer func() { t.tree = nil }()
+	}
+
+	if n.sectionEnabled(t, angiePath) {
+		start := time.Now()
+		err := n.gatherAngieMetrics(addr, acc)
+		t.scrape.section(angiePath, time.Since(start), err)
+		addError(acc, err)
+		if unreachable(err) {
+			return
+		}
+		if err == nil {
+			// Add the configuration generation to all other measurements, so
+			// counter resets caused by a reload can be told apart from real drops
+			acc = &generationAccumulator{Accumulator: acc, generation: t.generation}
+		}
+	} else if n.APIVersion == 0 && t.version == nil {
+		// The API version is detected from the angie section, which
+		// has to be requested separately when it is not gathered
+		err := n.gatherAPIVersion(addr, t)
+		t.scrape.failed(err)
+		addError(acc, err)
+		if unreachable(err) {
+			return
+		}
+	}
+
+	version := n.targetAPIVersion(t)
+
+	// Find out which sections this Angie instance provides on the first
+	// gather and after every reload. In tree fetch mode the tree is at hand
+	// anyway, so missing sections are simply not found there.
+	if n.FetchMode != fetchModeTree && t.available == nil {
+		err := n.discoverSections(addr, t, version)
+		t.scrape.failed(err)
+		addError(acc, err)
+		if unreachable(err) {
+			return
+		}
+	}
+
+	// Gather the sections, up to max_concurrent_requests_per_target at once
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(n.MaxConcurrentRequestsPerTarget, 1))
+	for _, s := range version.sections {
+		if !n.sectionEnabled(t, s.path) {
+			continue
+		}
+		if t.available != nil && !t.available[s.path] {
+			// Not configured in angie.conf, as opposed to without traffic
+			t.scrape.notFound()
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(s section) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := time.Now()
+			err := s.gather(n, addr, acc)
+			t.scrape.section(s.path, time.Since(start), err)
+			addError(acc, err)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// unreachable reports whether a request failed because the target could not
+// be reached. Its other sections are not requested then, as they would fail
+// the same way and only add more errors for the same cause.
+func unreachable(err error) bool {
+	return err != nil && errorClass(err) == errorClassNetwork
+}
+
+// sectionNames returns the names of the sections of all API versions, which
+// are their API paths.
+func sectionNames() []string {
+	names := []string{angiePath}
+	for _, v := range apiVersions {
+		for _, s := range v.sections {
+			if !slices.Contains(names, s.path) {
+				names = append(names, s.path)
+			}
+		}
+	}
+	return names
+}
+
+func (n *AngieAPI) discoverSections(addr *url.URL, t *target, version *apiVersion) error {
+	body, err := n.gatherURL(addr, "")
+	if err != nil {
+		return err
+	}
+
+	var root map[string]json.RawMessage
+	if err := decode(addr, "", body, &root); err != nil {
+		return err
+	}
+
+	available := make(map[string]bool, len(version.sections))
+	for _, s := range version.sections {
+		if !n.sectionEnabled(t, s.path) {
+			continue
+		}
+		if _, err := lookupPath(root, s.path); err != nil {
+			n.Log.Debugf("Section %q is not available at %q, skipping it", s.path, addr.String())
+			continue
+		}
+		available[s.path] = true
+	}
+	t.available = available
+
+	return nil
+}
+
+// generationAccumulator adds the configuration generation of the Angie
+// instance as a field to every metric.
+type generationAccumulator struct {
+	telegraf.Accumulator
+	generation int64
+}
+
+func (a *generationAccumulator) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	fields["generation"] = a.generation
+	a.Accumulator.AddFields(measurement, fields, tags, t...)
+}
+
+// tagsAccumulator adds the tags of a target table to every metric, without
+// overriding the tags set by the plugin.
+type tagsAccumulator struct {
+	telegraf.Accumulator
+	tags map[string]string
+}
+
+func (a *tagsAccumulator) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	for k, v := range a.tags {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+	a.Accumulator.AddFields(measurement, fields, tags, t...)
 }
 
 func addError(acc telegraf.Accumulator, err error) {
-	// This plugin has hardcoded API resource paths it checks that may not
-	// be in the angie.conf.  Currently, this is to prevent logging of
-	// paths that are not configured.
-	//
-	// The correct solution is to do a GET to /api to get the available paths
-	// on the server rather than simply ignore.
-	if !errors.Is(err, errNotFound) {
+	// Sections that are not configured in angie.conf are normally skipped
+	// after the discovery. Still ignore missing paths, as the discovery may
+	// have failed (in which case every section is tried) and in tree fetch
+	// mode sections are looked up without discovery.
+	// Requests skipped by an open circuit breaker are not reported either,
+	// only the failure that opened it.
+	if !errors.Is(err, errNotFound) && !errors.Is(err, errBreakerOpen) {
 		acc.AddError(err)
 	}
 }
 
+func (n *AngieAPI) gatherTree(addr *url.URL, t *target) error {
+	body, err := n.gatherURL(addr, "")
+	if err != nil {
+		return err
+	}
+
+	var tree map[string]json.RawMessage
+	if err := decode(addr, "", body, &tree); err != nil {
+		return err
+	}
+	t.tree = tree
+
+	return nil
+}
+
+// gatherPath returns the JSON document of the given API path, either by
+// requesting it or, in tree fetch mode, by looking it up in the status tree.
+// With schema_check enabled the document is checked against its type.
+func (n *AngieAPI) gatherPath(addr *url.URL, path string) ([]byte, error) {
+	body, err := n.fetchPath(addr, path)
+	if err != nil || n.SchemaCheck != schemaCheckWarn && n.SchemaCheck != schemaCheckStrict {
+		return body, err
+	}
+
+	if err := n.checkSectionSchema(addr, path, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (n *AngieAPI) fetchPath(addr *url.URL, path string) ([]byte, error) {
+	if n.FetchMode != fetchModeTree {
+		return n.gatherURL(addr, path)
+	}
+
+	body, err := lookupPath(n.target(addr).tree, path)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return nil, decodeError(addr, path, err)
+	}
+	return body, err
+}
+
+// lookupPath returns the JSON document at the given API path of a status tree.
+func lookupPath(tree map[string]json.RawMessage, path string) ([]byte, error) {
+	keys := strings.Split(path, "/")
+	body, ok := tree[keys[0]]
+	if !ok {
+		return nil, errNotFound
+	}
+	for _, key := range keys[1:] {
+		var node map[string]json.RawMessage
+		if err := json.Unmarshal(body, &node); err != nil {
+			return nil, err
+		}
+		if body, ok = node[key]; !ok {
+			return nil, errNotFound
+		}
+	}
+
+	return body, nil
+}
+
+// gatherURL requests the given API path, retrying transient errors. Its
+// outcome is recorded by the circuit breaker of the target.
 func (n *AngieAPI) gatherURL(addr *url.URL, path string) ([]byte, error) {
+	t := n.target(addr)
+	if n.CircuitBreakerFailures > 0 && !t.breaker.allow(time.Now(), time.Duration(n.CircuitBreakerTimeout)) {
+		return nil, errBreakerOpen
+	}
+
+	for retry := 0; ; retry++ {
+		body, err := n.request(addr, t, path)
+		if err == nil || !isTransient(err) || retry >= n.Retries {
+			if n.CircuitBreakerFailures > 0 {
+				t.breaker.record(isTransient(err), time.Now(), n.CircuitBreakerFailures)
+			}
+			return body, err
+		}
+
+		backoff := n.retryBackoff(retry)
+		n.Log.Debugf("Retrying %q in %s: %v", path, backoff, err)
+		time.Sleep(backoff)
+	}
+}
+
+// request makes a single request for the given API path.
+func (n *AngieAPI) request(addr *url.URL, t *target, path string) ([]byte, error) {
 	// Turn off pretty output to safe bandwidth
 	address := fmt.Sprintf("%s/%s?pretty=off", addr.String(), path)
-	resp, err := n.client.Get(address)
+	req, err := http.NewRequest(http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
 
+	client := n.client
+	if cfg := t.config; cfg != nil {
+		client = cfg.client
+		if err := setBasicAuth(req, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making HTTP request to %q: %w", address, err)
+		return nil, newGatherError(errorClassNetwork, addr, path, err)
 	}
 	defer resp.Body.Close()
+	t.scrape.responded()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-	case http.StatusNotFound:
-		// format as special error to catch and ignore as some Angie API
-		// features are either optional, or only available in some versions
-		return nil, errNotFound
-	default:
-		return nil, fmt.Errorf("%s returned HTTP status %s", address, resp.Status)
+	if resp.StatusCode != http.StatusOK {
+		// Not found errors match errNotFound, to catch and ignore them as
+		// some Angie API features are either optional, or only available in
+		// some versions
+		err := newGatherError(errorClassHTTPStatus, addr, path, fmt.Errorf("HTTP status %s", resp.Status))
+		err.status = resp.StatusCode
+		return nil, err
 	}
 
 	contentType := strings.Split(resp.Header.Get("Content-Type"), ";")[0]
 	switch contentType {
 	case "application/json":
 		body, err := io.ReadAll(resp.Body)
+		t.scrape.read(len(body))
 		if err != nil {
-			return nil, err
+			return nil, newGatherError(errorClassNetwork, addr, path, err)
 		}
 
 		return body, nil
 	default:
-		return nil, fmt.Errorf("%s returned unexpected content type %s", address, contentType)
+		return nil, newGatherError(errorClassContentType, addr, path, fmt.Errorf("unexpected content type %q", contentType))
+	}
+}
+
+// setBasicAuth adds the credentials of the target table, if any, to the request.
+func setBasicAuth(req *http.Request, cfg *Target) error {
+	if cfg.Username.Empty() && cfg.Password.Empty() {
+		return nil
 	}
+
+	username, err := cfg.Username.Get()
+	if err != nil {
+		return fmt.Errorf("getting username failed: %w", err)
+	}
+	defer username.Destroy()
+	password, err := cfg.Password.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer password.Destroy()
+
+	req.SetBasicAuth(username.String(), password.String())
+	return nil
 }
 
-func (n *AngieAPI) gatherProcessesMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, processesPath)
+func (n *AngieAPI) gatherAngieMetrics(addr *url.URL, acc telegraf.Accumulator) error {
+	body, err := n.gatherPath(addr, angiePath)
 	if err != nil {
 		return err
 	}
 
-	var processes = &processes{}
+	var angie = &angie{}
 
-	if err := json.Unmarshal(body, processes); err != nil {
+	if err := decode(addr, angiePath, body, angie); err != nil {
 		return err
 	}
 
+	// A changed generation or load time means the configuration was
+	// reloaded since the previous gather
+	t := n.target(addr)
+	reload := t.loadTime != "" && (t.generation != angie.Generation || t.loadTime != angie.LoadTime)
+	t.generation = angie.Generation
+	t.loadTime = angie.LoadTime
+	t.address = angie.Address
+	if reload {
+		// The set of sections may have changed with the new configuration,
+		// and the API version with an upgrade of Angie
+		t.available = nil
+		t.version = nil
+	}
+	if n.APIVersion == 0 && t.version == nil {
+		t.version = n.detectAPIVersion(addr, angie.Version)
+	}
+
+	fields := map[string]interface{}{
+		"version":    angie.Version,
+		"address":    angie.Address,
+		"generation": angie.Generation,
+		"load_time":  angie.LoadTime,
+		"reload":     reload,
+	}
+	// Optional build name (only present if Angie was built with one)
+	if angie.Build != nil {
+		fields["build"] = *angie.Build
+	}
+	// Config files are only listed when api_config_files is enabled
+	if angie.ConfigFiles != nil {
+		fields["config_files"] = len(angie.ConfigFiles)
+	}
+
+	acc.AddFields("angie_api_info", fields, n.getTags(addr))
+
+	return nil
+}
+
+func (n *AngieAPI) addProcessesMetrics(addr *url.URL, processes processes, acc telegraf.Accumulator) {
 	acc.AddFields(
 		"angie_api_processes",
 		map[string]interface{}{
 			"respawned": processes.Respawned,
 		},
-		getTags(addr),
+		n.getTags(addr),
 	)
-
-	return nil
 }
 
-func (n *AngieAPI) gatherConnectionsMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, connectionsPath)
-	if err != nil {
-		return err
-	}
-
-	var connections = &connections{}
-
-	if err := json.Unmarshal(body, connections); err != nil {
-		return err
-	}
-
+func (n *AngieAPI) addConnectionsMetrics(addr *url.URL, connections connections, acc telegraf.Accumulator) {
 	acc.AddFields(
 		"angie_api_connections",
 		map[string]interface{}{
@@ -123,25 +479,12 @@ func (n *AngieAPI) gatherConnectionsMetrics(addr *url.URL, acc telegraf.Accumula
 			"active":   connections.Active,
 			"idle":     connections.Idle,
 		},
-		getTags(addr),
+		n.getTags(addr),
 	)
-
-	return nil
 }
 
-func (n *AngieAPI) gatherSlabsMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, slabsPath)
-	if err != nil {
-		return err
-	}
-
-	var slabs slabs
-
-	if err := json.Unmarshal(body, &slabs); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addSlabsMetrics(addr *url.URL, slabs slabs, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for zoneName, slab := range slabs {
 		slabTags := make(map[string]string, len(tags)+1)
@@ -178,24 +521,14 @@ func (n *AngieAPI) gatherSlabsMetrics(addr *url.URL, acc telegraf.Accumulator) e
 			)
 		}
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherHTTPServerZonesMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, httpServerZonesPath)
-	if err != nil {
-		return err
-	}
-
-	var httpServerZones httpServerZones
-
-	if err := json.Unmarshal(body, &httpServerZones); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addHTTPServerZonesMetrics(addr *url.URL, httpServerZones httpServerZones, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 	for zoneName, zone := range httpServerZones {
+		if !matches(n.zoneFilter, zoneName) {
+			continue
+		}
 		zoneTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			zoneTags[k] = v
@@ -212,151 +545,8 @@ func (n *AngieAPI) gatherHTTPServerZonesMetrics(addr *url.URL, acc telegraf.Accu
 					"sent":                zone.Data.Sent,
 				}
 
-				// Response codes fields (only include those that are present)
-				if zone.Responses.Response100 != nil {
-					result["responses_100"] = *zone.Responses.Response100
-				}
-				if zone.Responses.Response101 != nil {
-					result["responses_101"] = *zone.Responses.Response101
-				}
-				if zone.Responses.Response102 != nil {
-					result["responses_102"] = *zone.Responses.Response102
-				}
-				if zone.Responses.Response200 != nil {
-					result["responses_200"] = *zone.Responses.Response200
-				}
-				if zone.Responses.Response201 != nil {
-					result["responses_201"] = *zone.Responses.Response201
-				}
-				if zone.Responses.Response202 != nil {
-					result["responses_202"] = *zone.Responses.Response202
-				}
-				if zone.Responses.Response203 != nil {
-					result["responses_203"] = *zone.Responses.Response203
-				}
-				if zone.Responses.Response204 != nil {
-					result["responses_204"] = *zone.Responses.Response204
-				}
-				if zone.Responses.Response205 != nil {
-					result["responses_205"] = *zone.Responses.Response205
-				}
-				if zone.Responses.Response206 != nil {
-					result["responses_206"] = *zone.Responses.Response206
-				}
-				if zone.Responses.Response300 != nil {
-					result["responses_300"] = *zone.Responses.Response300
-				}
-				if zone.Responses.Response301 != nil {
-					result["responses_301"] = *zone.Responses.Response301
-				}
-				if zone.Responses.Response302 != nil {
-					result["responses_302"] = *zone.Responses.Response302
-				}
-				if zone.Responses.Response303 != nil {
-					result["responses_303"] = *zone.Responses.Response303
-				}
-				if zone.Responses.Response304 != nil {
-					result["responses_304"] = *zone.Responses.Response304
-				}
-				if zone.Responses.Response305 != nil {
-					result["responses_305"] = *zone.Responses.Response305
-				}
-				if zone.Responses.Response307 != nil {
-					result["responses_307"] = *zone.Responses.Response307
-				}
-				if zone.Responses.Response308 != nil {
-					result["responses_308"] = *zone.Responses.Response308
-				}
-				if zone.Responses.Response400 != nil {
-					result["responses_400"] = *zone.Responses.Response400
-				}
-				if zone.Responses.Response401 != nil {
-					result["responses_401"] = *zone.Responses.Response401
-				}
-				if zone.Responses.Response402 != nil {
-					result["responses_402"] = *zone.Responses.Response402
-				}
-				if zone.Responses.Response403 != nil {
-					result["responses_403"] = *zone.Responses.Response403
-				}
-				if zone.Responses.Response404 != nil {
-					result["responses_404"] = *zone.Responses.Response404
-				}
-				if zone.Responses.Response405 != nil {
-					result["responses_405"] = *zone.Responses.Response405
-				}
-				if zone.Responses.Response406 != nil {
-					result["responses_406"] = *zone.Responses.Response406
-				}
-				if zone.Responses.Response407 != nil {
-					result["responses_407"] = *zone.Responses.Response407
-				}
-				if zone.Responses.Response408 != nil {
-					result["responses_408"] = *zone.Responses.Response408
-				}
-				if zone.Responses.Response409 != nil {
-					result["responses_409"] = *zone.Responses.Response409
-				}
-				if zone.Responses.Response410 != nil {
-					result["responses_410"] = *zone.Responses.Response410
-				}
-				if zone.Responses.Response411 != nil {
-					result["responses_411"] = *zone.Responses.Response411
-				}
-				if zone.Responses.Response412 != nil {
-					result["responses_412"] = *zone.Responses.Response412
-				}
-				if zone.Responses.Response413 != nil {
-					result["responses_413"] = *zone.Responses.Response413
-				}
-				if zone.Responses.Response421 != nil {
-					result["responses_421"] = *zone.Responses.Response421
-				}
-				if zone.Responses.Response422 != nil {
-					result["responses_422"] = *zone.Responses.Response422
-				}
-				if zone.Responses.Response423 != nil {
-					result["responses_423"] = *zone.Responses.Response423
-				}
-				if zone.Responses.Response424 != nil {
-					result["responses_424"] = *zone.Responses.Response424
-				}
-				if zone.Responses.Response425 != nil {
-					result["responses_425"] = *zone.Responses.Response425
-				}
-				if zone.Responses.Response426 != nil {
-					result["responses_426"] = *zone.Responses.Response426
-				}
-				if zone.Responses.Response428 != nil {
-					result["responses_428"] = *zone.Responses.Response428
-				}
-				if zone.Responses.Response429 != nil {
-					result["responses_429"] = *zone.Responses.Response429
-				}
-				if zone.Responses.Response431 != nil {
-					result["responses_431"] = *zone.Responses.Response431
-				}
-				if zone.Responses.Response500 != nil {
-					result["responses_500"] = *zone.Responses.Response500
-				}
-				if zone.Responses.Response501 != nil {
-					result["responses_501"] = *zone.Responses.Response501
-				}
-				if zone.Responses.Response502 != nil {
-					result["responses_502"] = *zone.Responses.Response502
-				}
-				if zone.Responses.Response503 != nil {
-					result["responses_503"] = *zone.Responses.Response503
-				}
-				if zone.Responses.Response504 != nil {
-					result["responses_504"] = *zone.Responses.Response504
-				}
-				if zone.Responses.Response505 != nil {
-					result["responses_505"] = *zone.Responses.Response505
-				}
-				if zone.Responses.Response511 != nil {
-					result["responses_511"] = *zone.Responses.Response511
-				}
+				// Response codes fields (only those that are present)
+				n.addResponses(result, zone.Responses)
 
 				// SSL (if present)
 				if zone.Ssl != nil {
@@ -370,25 +560,15 @@ func (n *AngieAPI) gatherHTTPServerZonesMetrics(addr *url.URL, acc telegraf.Accu
 			zoneTags,
 		)
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherHTTPLocationZonesMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, httpLocationZonesPath)
-	if err != nil {
-		return err
-	}
-
-	var httpLocationZones httpLocationZones
-
-	if err := json.Unmarshal(body, &httpLocationZones); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addHTTPLocationZonesMetrics(addr *url.URL, httpLocationZones httpLocationZones, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for zoneName, zone := range httpLocationZones {
+		if !matches(n.zoneFilter, zoneName) {
+			continue
+		}
 		zoneTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			zoneTags[k] = v
@@ -405,175 +585,22 @@ func (n *AngieAPI) gatherHTTPLocationZonesMetrics(addr *url.URL, acc telegraf.Ac
 					"sent":                zone.Data.Sent,
 				}
 
-				// Response codes fields (only include those that are present)
-				if zone.Responses.Response100 != nil {
-					result["responses_100"] = *zone.Responses.Response100
-				}
-				if zone.Responses.Response101 != nil {
-					result["responses_101"] = *zone.Responses.Response101
-				}
-				if zone.Responses.Response102 != nil {
-					result["responses_102"] = *zone.Responses.Response102
-				}
-				if zone.Responses.Response200 != nil {
-					result["responses_200"] = *zone.Responses.Response200
-				}
-				if zone.Responses.Response201 != nil {
-					result["responses_201"] = *zone.Responses.Response201
-				}
-				if zone.Responses.Response202 != nil {
-					result["responses_202"] = *zone.Responses.Response202
-				}
-				if zone.Responses.Response203 != nil {
-					result["responses_203"] = *zone.Responses.Response203
-				}
-				if zone.Responses.Response204 != nil {
-					result["responses_204"] = *zone.Responses.Response204
-				}
-				if zone.Responses.Response205 != nil {
-					result["responses_205"] = *zone.Responses.Response205
-				}
-				if zone.Responses.Response206 != nil {
-					result["responses_206"] = *zone.Responses.Response206
-				}
-				if zone.Responses.Response300 != nil {
-					result["responses_300"] = *zone.Responses.Response300
-				}
-				if zone.Responses.Response301 != nil {
-					result["responses_301"] = *zone.Responses.Response301
-				}
-				if zone.Responses.Response302 != nil {
-					result["responses_302"] = *zone.Responses.Response302
-				}
-				if zone.Responses.Response303 != nil {
-					result["responses_303"] = *zone.Responses.Response303
-				}
-				if zone.Responses.Response304 != nil {
-					result["responses_304"] = *zone.Responses.Response304
-				}
-				if zone.Responses.Response305 != nil {
-					result["responses_305"] = *zone.Responses.Response305
-				}
-				if zone.Responses.Response307 != nil {
-					result["responses_307"] = *zone.Responses.Response307
-				}
-				if zone.Responses.Response308 != nil {
-					result["responses_308"] = *zone.Responses.Response308
-				}
-				if zone.Responses.Response400 != nil {
-					result["responses_400"] = *zone.Responses.Response400
-				}
-				if zone.Responses.Response401 != nil {
-					result["responses_401"] = *zone.Responses.Response401
-				}
-				if zone.Responses.Response402 != nil {
-					result["responses_402"] = *zone.Responses.Response402
-				}
-				if zone.Responses.Response403 != nil {
-					result["responses_403"] = *zone.Responses.Response403
-				}
-				if zone.Responses.Response404 != nil {
-					result["responses_404"] = *zone.Responses.Response404
-				}
-				if zone.Responses.Response405 != nil {
-					result["responses_405"] = *zone.Responses.Response405
-				}
-				if zone.Responses.Response406 != nil {
-					result["responses_406"] = *zone.Responses.Response406
-				}
-				if zone.Responses.Response407 != nil {
-					result["responses_407"] = *zone.Responses.Response407
-				}
-				if zone.Responses.Response408 != nil {
-					result["responses_408"] = *zone.Responses.Response408
-				}
-				if zone.Responses.Response409 != nil {
-					result["responses_409"] = *zone.Responses.Response409
-				}
-				if zone.Responses.Response410 != nil {
-					result["responses_410"] = *zone.Responses.Response410
-				}
-				if zone.Responses.Response411 != nil {
-					result["responses_411"] = *zone.Responses.Response411
-				}
-				if zone.Responses.Response412 != nil {
-					result["responses_412"] = *zone.Responses.Response412
-				}
-				if zone.Responses.Response413 != nil {
-					result["responses_413"] = *zone.Responses.Response413
-				}
-				if zone.Responses.Response421 != nil {
-					result["responses_421"] = *zone.Responses.Response421
-				}
-				if zone.Responses.Response422 != nil {
-					result["responses_422"] = *zone.Responses.Response422
-				}
-				if zone.Responses.Response423 != nil {
-					result["responses_423"] = *zone.Responses.Response423
-				}
-				if zone.Responses.Response424 != nil {
-					result["responses_424"] = *zone.Responses.Response424
-				}
-				if zone.Responses.Response425 != nil {
-					result["responses_425"] = *zone.Responses.Response425
-				}
-				if zone.Responses.Response426 != nil {
-					result["responses_426"] = *zone.Responses.Response426
-				}
-				if zone.Responses.Response428 != nil {
-					result["responses_428"] = *zone.Responses.Response428
-				}
-				if zone.Responses.Response429 != nil {
-					result["responses_429"] = *zone.Responses.Response429
-				}
-				if zone.Responses.Response431 != nil {
-					result["responses_431"] = *zone.Responses.Response431
-				}
-				if zone.Responses.Response500 != nil {
-					result["responses_500"] = *zone.Responses.Response500
-				}
-				if zone.Responses.Response501 != nil {
-					result["responses_501"] = *zone.Responses.Response501
-				}
-				if zone.Responses.Response502 != nil {
-					result["responses_502"] = *zone.Responses.Response502
-				}
-				if zone.Responses.Response503 != nil {
-					result["responses_503"] = *zone.Responses.Response503
-				}
-				if zone.Responses.Response504 != nil {
-					result["responses_504"] = *zone.Responses.Response504
-				}
-				if zone.Responses.Response505 != nil {
-					result["responses_505"] = *zone.Responses.Response505
-				}
-				if zone.Responses.Response511 != nil {
-					result["responses_511"] = *zone.Responses.Response511
-				}
+				// Response codes fields (only those that are present)
+				n.addResponses(result, zone.Responses)
 				return result
 			}(),
 			zoneTags,
 		)
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherHTTPUpstreamsMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, httpUpstreamsPath)
-	if err != nil {
-		return err
-	}
-
-	var httpUpstreams httpUpstreams
-
-	if err := json.Unmarshal(body, &httpUpstreams); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addHTTPUpstreamsMetrics(addr *url.URL, httpUpstreams httpUpstreams, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for upstreamName, upstream := range httpUpstreams {
+		if !matches(n.upstreamFilter, upstreamName) {
+			continue
+		}
 		upstreamTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			upstreamTags[k] = v
@@ -582,6 +609,14 @@ func (n *AngieAPI) gatherHTTPUpstreamsMetrics(addr *url.URL, acc telegraf.Accumu
 		upstreamFields := map[string]interface{}{
 			"keepalive": upstream.Keepalive,
 		}
+		// Optional request queue (only present if configured)
+		if upstream.Queue != nil {
+			upstreamFields["queue_queued"] = upstream.Queue.Queued
+			upstreamFields["queue_waiting"] = upstream.Queue.Waiting
+			upstreamFields["queue_dropped"] = upstream.Queue.Dropped
+			upstreamFields["queue_timedout"] = upstream.Queue.TimedOut
+			upstreamFields["queue_overflows"] = upstream.Queue.Overflows
+		}
 		acc.AddFields(
 			"angie_api_http_upstreams",
 			upstreamFields,
@@ -589,6 +624,7 @@ func (n *AngieAPI) gatherHTTPUpstreamsMetrics(addr *url.URL, acc telegraf.Accumu
 		)
 		for peerName, peer := range upstream.Peers {
 			peerFields := map[string]interface{}{
+				"server":             peer.Server,
 				"backup":             peer.Backup,
 				"weight":             peer.Weight,
 				"state":              peer.State,
@@ -608,153 +644,25 @@ func (n *AngieAPI) gatherHTTPUpstreamsMetrics(addr *url.URL, acc telegraf.Accumu
 			if peer.Health.Downstart != nil {
 				peerFields["health_downstart"] = *peer.Health.Downstart
 			}
-
-			// Only include codes fields that are present (and only the important ones)
-			if peer.Responses.Response100 != nil {
-				peerFields["responses_100"] = peer.Responses.Response100
-			}
-			if peer.Responses.Response101 != nil {
-				peerFields["responses_101"] = peer.Responses.Response101
-			}
-			if peer.Responses.Response102 != nil {
-				peerFields["responses_102"] = peer.Responses.Response102
-			}
-			if peer.Responses.Response200 != nil {
-				peerFields["responses_200"] = peer.Responses.Response200
-			}
-			if peer.Responses.Response201 != nil {
-				peerFields["responses_201"] = peer.Responses.Response201
-			}
-			if peer.Responses.Response202 != nil {
-				peerFields["responses_202"] = peer.Responses.Response202
-			}
-			if peer.Responses.Response203 != nil {
-				peerFields["responses_203"] = peer.Responses.Response203
-			}
-			if peer.Responses.Response204 != nil {
-				peerFields["responses_204"] = peer.Responses.Response204
-			}
-			if peer.Responses.Response205 != nil {
-				peerFields["responses_205"] = peer.Responses.Response205
-			}
-			if peer.Responses.Response206 != nil {
-				peerFields["responses_206"] = peer.Responses.Response206
-			}
-			if peer.Responses.Response300 != nil {
-				peerFields["responses_300"] = peer.Responses.Response300
-			}
-			if peer.Responses.Response301 != nil {
-				peerFields["responses_301"] = peer.Responses.Response301
-			}
-			if peer.Responses.Response302 != nil {
-				peerFields["responses_302"] = peer.Responses.Response302
-			}
-			if peer.Responses.Response303 != nil {
-				peerFields["responses_303"] = peer.Responses.Response303
-			}
-			if peer.Responses.Response304 != nil {
-				peerFields["responses_304"] = peer.Responses.Response304
-			}
-			if peer.Responses.Response305 != nil {
-				peerFields["responses_305"] = peer.Responses.Response305
-			}
-			if peer.Responses.Response307 != nil {
-				peerFields["responses_307"] = peer.Responses.Response307
-			}
-			if peer.Responses.Response308 != nil {
-				peerFields["responses_308"] = peer.Responses.Response308
-			}
-			if peer.Responses.Response400 != nil {
-				peerFields["responses_400"] = peer.Responses.Response400
-			}
-			if peer.Responses.Response401 != nil {
-				peerFields["responses_401"] = peer.Responses.Response401
-			}
-			if peer.Responses.Response402 != nil {
-				peerFields["responses_402"] = peer.Responses.Response402
-			}
-			if peer.Responses.Response403 != nil {
-				peerFields["responses_403"] = peer.Responses.Response403
-			}
-			if peer.Responses.Response404 != nil {
-				peerFields["responses_404"] = peer.Responses.Response404
-			}
-			if peer.Responses.Response405 != nil {
-				peerFields["responses_405"] = peer.Responses.Response405
-			}
-			if peer.Responses.Response406 != nil {
-				peerFields["responses_406"] = peer.Responses.Response406
-			}
-			if peer.Responses.Response407 != nil {
-				peerFields["responses_407"] = peer.Responses.Response407
-			}
-			if peer.Responses.Response408 != nil {
-				peerFields["responses_408"] = peer.Responses.Response408
-			}
-			if peer.Responses.Response409 != nil {
-				peerFields["responses_409"] = peer.Responses.Response409
-			}
-			if peer.Responses.Response410 != nil {
-				peerFields["responses_410"] = peer.Responses.Response410
-			}
-			if peer.Responses.Response411 != nil {
-				peerFields["responses_411"] = peer.Responses.Response411
-			}
-			if peer.Responses.Response412 != nil {
-				peerFields["responses_412"] = peer.Responses.Response412
-			}
-			if peer.Responses.Response413 != nil {
-				peerFields["responses_413"] = peer.Responses.Response413
-			}
-			if peer.Responses.Response421 != nil {
-				peerFields["responses_421"] = peer.Responses.Response421
-			}
-			if peer.Responses.Response422 != nil {
-				peerFields["responses_422"] = peer.Responses.Response422
-			}
-			if peer.Responses.Response423 != nil {
-				peerFields["responses_423"] = peer.Responses.Response423
-			}
-			if peer.Responses.Response424 != nil {
-				peerFields["responses_424"] = peer.Responses.Response424
-			}
-			if peer.Responses.Response425 != nil {
-				peerFields["responses_425"] = peer.Responses.Response425
-			}
-			if peer.Responses.Response426 != nil {
-				peerFields["responses_426"] = peer.Responses.Response426
-			}
-			if peer.Responses.Response428 != nil {
-				peerFields["responses_428"] = peer.Responses.Response428
-			}
-			if peer.Responses.Response429 != nil {
-				peerFields["responses_429"] = peer.Responses.Response429
-			}
-			if peer.Responses.Response431 != nil {
-				peerFields["responses_431"] = peer.Responses.Response431
-			}
-			if peer.Responses.Response500 != nil {
-				peerFields["responses_500"] = peer.Responses.Response500
-			}
-			if peer.Responses.Response501 != nil {
-				peerFields["responses_501"] = peer.Responses.Response501
-			}
-			if peer.Responses.Response502 != nil {
-				peerFields["responses_502"] = peer.Responses.Response502
-			}
-			if peer.Responses.Response503 != nil {
-				peerFields["responses_503"] = peer.Responses.Response503
-			}
-			if peer.Responses.Response504 != nil {
-				peerFields["responses_504"] = peer.Responses.Response504
+			// Optional active health check probes
+			if peer.Health.Probes != nil {
+				peerFields["health_probes_count"] = peer.Health.Probes.Count
+				peerFields["health_probes_fails"] = peer.Health.Probes.Fails
+				if peer.Health.Probes.Last != nil {
+					peerFields["health_probes_last"] = *peer.Health.Probes.Last
+				}
 			}
-			if peer.Responses.Response505 != nil {
-				peerFields["responses_505"] = peer.Responses.Response505
+			// Optional response timings (only present once the peer was used)
+			if peer.Health.HeaderTime != nil {
+				peerFields["header_time"] = *peer.Health.HeaderTime
 			}
-			if peer.Responses.Response511 != nil {
-				peerFields["responses_511"] = peer.Responses.Response511
+			if peer.Health.ResponseTime != nil {
+				peerFields["response_time"] = *peer.Health.ResponseTime
 			}
 
+			// Response codes fields (only those that are present)
+			n.addResponses(peerFields, peer.Responses)
+
 			// Other optional fields
 			if peer.Service != nil {
 				peerFields["service"] = *peer.Service
@@ -771,22 +679,10 @@ func (n *AngieAPI) gatherHTTPUpstreamsMetrics(addr *url.URL, acc telegraf.Accumu
 			acc.AddFields("angie_api_http_upstream_peers", peerFields, peerTags)
 		}
 	}
-	return nil
 }
 
-func (n *AngieAPI) gatherHTTPCachesMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, httpCachesPath)
-	if err != nil {
-		return err
-	}
-
-	var httpCaches httpCaches
-
-	if err := json.Unmarshal(body, &httpCaches); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addHTTPCachesMetrics(addr *url.URL, httpCaches httpCaches, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for cacheName, cache := range httpCaches {
 		cacheTags := make(map[string]string, len(tags)+1)
@@ -824,23 +720,10 @@ func (n *AngieAPI) gatherHTTPCachesMetrics(addr *url.URL, acc telegraf.Accumulat
 			cacheTags,
 		)
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherResolverZonesMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, resolverZonesPath)
-	if err != nil {
-		return err
-	}
-
-	var resolverZones resolverZones
-
-	if err := json.Unmarshal(body, &resolverZones); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addResolverZonesMetrics(addr *url.URL, resolverZones resolverZones, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for zoneName, resolver := range resolverZones {
 		zoneTags := make(map[string]string, len(tags)+1)
@@ -870,25 +753,15 @@ func (n *AngieAPI) gatherResolverZonesMetrics(addr *url.URL, acc telegraf.Accumu
 			zoneTags,
 		)
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherHTTPLimitReqsMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, httpLimitReqsPath)
-	if err != nil {
-		return err
-	}
-
-	var httpLimitReqs httpLimitReqs
-
-	if err := json.Unmarshal(body, &httpLimitReqs); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addHTTPLimitReqsMetrics(addr *url.URL, httpLimitReqs httpLimitReqs, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for limitReqName, limit := range httpLimitReqs {
+		if !matches(n.limitFilter, limitReqName) {
+			continue
+		}
 		limitReqsTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			limitReqsTags[k] = v
@@ -906,25 +779,15 @@ func (n *AngieAPI) gatherHTTPLimitReqsMetrics(addr *url.URL, acc telegraf.Accumu
 			limitReqsTags,
 		)
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherHTTPLimitConnsMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, httpLimitConnsPath)
-	if err != nil {
-		return err
-	}
-
-	var httpLimitConns limitConns
-
-	if err := json.Unmarshal(body, &httpLimitConns); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addHTTPLimitConnsMetrics(addr *url.URL, httpLimitConns limitConns, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for limitConnName, limit := range httpLimitConns {
+		if !matches(n.limitFilter, limitConnName) {
+			continue
+		}
 		limitConnsTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			limitConnsTags[k] = v
@@ -941,25 +804,15 @@ func (n *AngieAPI) gatherHTTPLimitConnsMetrics(addr *url.URL, acc telegraf.Accum
 			limitConnsTags,
 		)
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherStreamServerZonesMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, streamServerZonesPath)
-	if err != nil {
-		return err
-	}
-
-	var streamServerZones streamServerZones
-
-	if err := json.Unmarshal(body, &streamServerZones); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addStreamServerZonesMetrics(addr *url.URL, streamServerZones streamServerZones, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for zoneName, zone := range streamServerZones {
+		if !matches(n.zoneFilter, zoneName) {
+			continue
+		}
 		zoneTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			zoneTags[k] = v
@@ -994,25 +847,15 @@ func (n *AngieAPI) gatherStreamServerZonesMetrics(addr *url.URL, acc telegraf.Ac
 			zoneTags,
 		)
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherStreamUpstreamsMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, streamUpstreamsPath)
-	if err != nil {
-		return err
-	}
-
-	var streamUpstreams streamUpstreams
-
-	if err := json.Unmarshal(body, &streamUpstreams); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addStreamUpstreamsMetrics(addr *url.URL, streamUpstreams streamUpstreams, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for upstreamName, upstream := range streamUpstreams {
+		if !matches(n.upstreamFilter, upstreamName) {
+			continue
+		}
 		upstreamTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			upstreamTags[k] = v
@@ -1020,6 +863,7 @@ func (n *AngieAPI) gatherStreamUpstreamsMetrics(addr *url.URL, acc telegraf.Accu
 		upstreamTags["upstream"] = upstreamName
 		for peerName, peer := range upstream.Peers {
 			peerFields := map[string]interface{}{
+				"server":             peer.Server,
 				"backup":             peer.Backup,
 				"weight":             peer.Weight,
 				"state":              peer.State,
@@ -1044,6 +888,23 @@ func (n *AngieAPI) gatherStreamUpstreamsMetrics(addr *url.URL, acc telegraf.Accu
 			if peer.Health.Downstart != nil {
 				peerFields["health_downstart"] = *peer.Health.Downstart
 			}
+			// Optional active health check probes
+			if peer.Health.Probes != nil {
+				peerFields["health_probes_count"] = peer.Health.Probes.Count
+				peerFields["health_probes_fails"] = peer.Health.Probes.Fails
+				if peer.Health.Probes.Last != nil {
+					peerFields["health_probes_last"] = *peer.Health.Probes.Last
+				}
+			}
+			if peer.Health.ConnectTime != nil {
+				peerFields["connect_time"] = *peer.Health.ConnectTime
+			}
+			if peer.Health.FirstByteTime != nil {
+				peerFields["first_byte_time"] = *peer.Health.FirstByteTime
+			}
+			if peer.Health.LastByteTime != nil {
+				peerFields["last_byte_time"] = *peer.Health.LastByteTime
+			}
 			peerTags := make(map[string]string, len(upstreamTags)+1)
 			for k, v := range upstreamTags {
 				peerTags[k] = v
@@ -1052,25 +913,15 @@ func (n *AngieAPI) gatherStreamUpstreamsMetrics(addr *url.URL, acc telegraf.Accu
 			acc.AddFields("angie_api_stream_upstream_peers", peerFields, peerTags)
 		}
 	}
-
-	return nil
 }
 
-func (n *AngieAPI) gatherStreamLimitConnsMetrics(addr *url.URL, acc telegraf.Accumulator) error {
-	body, err := n.gatherURL(addr, streamLimitConnsPath)
-	if err != nil {
-		return err
-	}
-
-	var streamLimitConns limitConns
-
-	if err := json.Unmarshal(body, &streamLimitConns); err != nil {
-		return err
-	}
-
-	tags := getTags(addr)
+func (n *AngieAPI) addStreamLimitConnsMetrics(addr *url.URL, streamLimitConns limitConns, acc telegraf.Accumulator) {
+	tags := n.getTags(addr)
 
 	for limitConnName, limit := range streamLimitConns {
+		if !matches(n.limitFilter, limitConnName) {
+			continue
+		}
 		limitConnsTags := make(map[string]string, len(tags)+1)
 		for k, v := range tags {
 			limitConnsTags[k] = v
@@ -1087,11 +938,72 @@ func (n *AngieAPI) gatherStreamLimitConnsMetrics(addr *url.URL, acc telegraf.Acc
 			limitConnsTags,
 		)
 	}
+}
 
-	return nil
+// addResponses adds a responses_<code> field for every HTTP status code
+// reported, including non-standard ones like 444 or 499, and/or the
+// responses_1xx to responses_5xx and responses_total fields, depending on
+// the response_codes setting.
+func (n *AngieAPI) addResponses(fields map[string]interface{}, responses responseStats) {
+	if n.ResponseCodes != responseCodesClass {
+		for code, count := range responses {
+			fields["responses_"+code] = count
+		}
+	}
+
+	if n.ResponseCodes == responseCodesClass || n.ResponseCodes == responseCodesBoth {
+		var classes [5]int64
+		var total int64
+		for code, count := range responses {
+			if len(code) == 3 && code[0] >= '1' && code[0] <= '5' {
+				classes[code[0]-'1'] += count
+			}
+			total += count
+		}
+		for i, count := range classes {
+			fields[fmt.Sprintf("responses_%dxx", i+1)] = count
+		}
+		fields["responses_total"] = total
+	}
 }
 
-func getTags(addr *url.URL) map[string]string {
+// getTags returns the source and port tags of the Angie API at the given URL.
+// The source is taken from the URL unless configured otherwise.
+func (n *AngieAPI) getTags(addr *url.URL) map[string]string {
+	tags := urlTags(addr)
+
+	t := n.target(addr)
+	sourceFrom := n.SourceFrom
+	if t.config != nil {
+		if t.config.Source != "" {
+			tags["source"] = t.config.Source
+			return tags
+		}
+		if t.config.SourceFrom != "" {
+			sourceFrom = t.config.SourceFrom
+		}
+	}
+
+	switch sourceFrom {
+	case sourceFromHostname:
+		tags["source"] = n.hostname
+	case sourceFromAddress:
+		// Only known once the angie section was gathered
+		if t.address != "" {
+			tags["source"] = t.address
+		}
+	}
+	return tags
+}
+
+// urlTags returns the source and port tags derived from the URL. Unix socket
+// URLs have no port, so they only get the source tag.
+func urlTags(addr *url.URL) map[string]string {
+	if strings.HasSuffix(addr.Scheme, "+unix") {
+		socket, _, _ := strings.Cut(addr.Path, ":")
+		return map[string]string{"source": socket}
+	}
+
 	h := addr.Host
 	host, port, err := net.SplitHostPort(h)
 	if err != nil {
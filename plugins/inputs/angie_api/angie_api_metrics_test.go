@@ -6,13 +6,32 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/testutil"
 )
 
+const angiePayload = `
+{
+	"version": "1.10.2",
+	"build": "PRO",
+	"address": "192.168.16.5",
+	"generation": 3,
+	"load_time": "2025-11-22T21:27:55.136Z",
+	"config_files": {
+		"/etc/angie/angie.conf": "...",
+		"/etc/angie/mime.types": "..."
+	}
+}
+`
+
 const processesPayload = `
 {
 	"respawned": 0
@@ -168,37 +187,49 @@ const sslPayload = `
 const resolverZonesPayload = `
 {
   "resolver_zone1": {
-    "requests": {
+    "queries": {
       "name": 25460,
       "srv": 130,
       "addr": 2580
     },
     "responses": {
-      "noerror": 26499,
-      "formerr": 0,
-      "servfail": 3,
-      "nxdomain": 0,
-      "notimp": 0,
-      "refused": 0,
+      "success": 26499,
       "timedout": 243,
-      "unknown": 478
+      "format_error": 0,
+      "server_failure": 3,
+      "not_found": 0,
+      "unimplemented": 0,
+      "refused": 0,
+      "other": 478
+    },
+    "sent": {
+      "a": 25380,
+      "aaaa": 25380,
+      "srv": 130,
+      "ptr": 2580
     }
   },
   "resolver_zone2": {
-    "requests": {
+    "queries": {
       "name": 325460,
       "srv": 1130,
       "addr": 12580
     },
     "responses": {
-      "noerror": 226499,
-      "formerr": 0,
-      "servfail": 283,
-      "nxdomain": 0,
-      "notimp": 0,
-      "refused": 0,
+      "success": 226499,
       "timedout": 743,
-      "unknown": 1478
+      "format_error": 0,
+      "server_failure": 283,
+      "not_found": 0,
+      "unimplemented": 0,
+      "refused": 0,
+      "other": 1478
+    },
+    "sent": {
+      "a": 325380,
+      "aaaa": 325380,
+      "srv": 1130,
+      "ptr": 12580
     }
   }
 }
@@ -214,86 +245,102 @@ const httpRequestsPayload = `
 const httpServerZonesPayload = `
 {
 	"site1": {
-		"processing": 2,
-		"requests": 736395,
+		"ssl": {
+			"handshaked": 4174,
+			"reuses": 1250,
+			"timedout": 3,
+			"failed": 12
+		},
+		"requests": {
+			"total": 736395,
+			"processing": 2,
+			"discarded": 2020
+		},
 		"responses": {
-			"1xx": 0,
-			"2xx": 727290,
-			"3xx": 4614,
-			"4xx": 934,
-			"5xx": 1535,
-			"total": 734373
+			"200": 727290,
+			"301": 4614,
+			"404": 934,
+			"503": 1535
 		},
-		"discarded": 2020,
-		"received": 180157219,
-		"sent": 20183175459
+		"data": {
+			"received": 180157219,
+			"sent": 20183175459
+		}
 	},
 	"site2": {
-		"processing": 1,
-		"requests": 185307,
+		"requests": {
+			"total": 185307,
+			"processing": 1,
+			"discarded": 20326
+		},
 		"responses": {
-			"1xx": 0,
-			"2xx": 112674,
-			"3xx": 45383,
-			"4xx": 2504,
-			"5xx": 4419,
-			"total": 164980
+			"200": 112674,
+			"304": 45383,
+			"404": 2504,
+			"502": 4419
 		},
-		"discarded": 20326,
-		"received": 51575327,
-		"sent": 2983241510
+		"data": {
+			"received": 51575327,
+			"sent": 2983241510
+		}
 	}
 }
 `
 
 const httpLimitReqsPayload = `
 {
-        "limit_1": {
-                "passed": 2,
-                "delayed": 9,
-                "rejected": 4,
-                "delayed_dry_run": 100,
-                "rejected_dry_run": 330
-        },
-        "limit_2": {
-                "passed": 451,
-                "delayed": 10,
-                "rejected": 0,
-                "delayed_dry_run": 10,
-                "rejected_dry_run": 32
-        }
+	"limit_1": {
+		"passed": 2,
+		"skipped": 0,
+		"delayed": 9,
+		"rejected": 4,
+		"exhausted": 0
+	},
+	"limit_2": {
+		"passed": 451,
+		"skipped": 3,
+		"delayed": 10,
+		"rejected": 0,
+		"exhausted": 1
+	}
 }
 `
 
 const httpLocationZonesPayload = `
 {
   "site1": {
-    "requests": 736395,
+    "requests": {
+      "total": 736395,
+      "processing": 3,
+      "discarded": 2020
+    },
     "responses": {
-      "1xx": 0,
-      "2xx": 727290,
-      "3xx": 4614,
-      "4xx": 934,
-      "5xx": 1535,
-      "total": 734373
+      "200": 727290,
+      "302": 4614,
+      "404": 934,
+      "500": 1535
     },
-    "discarded": 2020,
-    "received": 180157219,
-    "sent": 20183175459
+    "data": {
+      "received": 180157219,
+      "sent": 20183175459
+    }
   },
   "site2": {
-    "requests": 185307,
+    "requests": {
+      "total": 185307,
+      "processing": 0,
+      "discarded": 20326
+    },
     "responses": {
-      "1xx": 0,
-      "2xx": 112674,
-      "3xx": 45383,
-      "4xx": 2504,
-      "5xx": 4419,
-      "total": 164980
+      "200": 112674,
+      "304": 45383,
+      "403": 2504,
+      "504": 4419
     },
-    "discarded": 20326,
-    "received": 51575327,
-    "sent": 2983241510
+    "data": {
+      "received": 51575327,
+      "sent": 2983241510
+    }
   }
 }
 `
@@ -301,146 +348,102 @@ const httpLocationZonesPayload = `
 const httpUpstreamsPayload = `
 {
 	"trac-backend": {
-		"peers": [
-		{
-			"id": 0,
-			"server": "10.0.0.1:8088",
-			"name": "10.0.0.1:8088",
-			"backup": false,
-			"weight": 5,
-			"state": "up",
-			"active": 0,
-			"requests": 667231,
-			"header_time": 20,
-			"response_time": 36,
-			"responses": {
-				"1xx": 0,
-				"2xx": 666310,
-				"3xx": 0,
-				"4xx": 915,
-				"5xx": 6,
-				"total": 667231
-			},
-			"sent": 251946292,
-			"received": 19222475454,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26214,
-				"fails": 0,
-				"unhealthy": 0,
-				"last_passed": true
+		"peers": {
+			"10.0.0.1:8088": {
+				"server": "10.0.0.1:8088",
+				"backup": false,
+				"weight": 5,
+				"state": "up",
+				"selected": {
+					"current": 2,
+					"total": 667231,
+					"last": "2025-11-22T21:28:00Z"
+				},
+				"max_conns": 100,
+				"responses": {
+					"200": 666310,
+					"404": 915,
+					"502": 6
+				},
+				"data": {
+					"sent": 251946292,
+					"received": 19222475454
+				},
+				"health": {
+					"fails": 0,
+					"unavailable": 0,
+					"downtime": 0,
+					"header_time": 20,
+					"response_time": 36
+				},
+				"sid": "0349acf60535cd8bdf89fb53de0f959e"
 			},
-			"downtime": 0,
-			"downstart": {},
-			"selected": {}
+			"10.0.0.1:8089": {
+				"server": "10.0.0.1:8089",
+				"backup": true,
+				"weight": 1,
+				"state": "unavailable",
+				"selected": {
+					"current": 0,
+					"total": 0
+				},
+				"responses": {},
+				"data": {
+					"sent": 0,
+					"received": 0
+				},
+				"health": {
+					"fails": 26284,
+					"unavailable": 1,
+					"downtime": 262925617,
+					"downstart": "2025-11-20T08:12:45.387Z",
+					"probes": {
+						"count": 26284,
+						"fails": 26284,
+						"last": "2025-11-22T21:27:58.103Z"
+					}
+				},
+				"sid": "adbdc4c737eef0c63976e2f697c8c8b3"
+			}
 		},
-		{
-			"id": 1,
-			"server": "10.0.0.1:8089",
-			"name": "10.0.0.1:8089",
-			"backup": true,
-			"weight": 1,
-			"state": "unhealthy",
-			"active": 0,
-			"requests": 0,
-			"responses": {
-				"1xx": 0,
-				"2xx": 0,
-				"3xx": 0,
-				"4xx": 0,
-				"5xx": 0,
-				"total": 0
-			},
-			"sent": 0,
-			"received": 0,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26284,
-				"fails": 26284,
-				"unhealthy": 1,
-				"last_passed": false
-			},
-			"downtime": 262925617,
-			"downstart": {},
-			"selected": {}
+		"keepalive": 4,
+		"queue": {
+			"queued": 20112,
+			"waiting": 1011,
+			"dropped": 6031,
+			"timedout": 560,
+			"overflows": 13
 		}
-		],
-		"keepalive": 0,
-		"zombies": 0,
-		"zone": "trac-backend"
 	},
 	"hg-backend": {
-		"peers": [
-		{
-			"id": 0,
-			"server": "10.0.0.1:8088",
-			"name": "10.0.0.1:8088",
-			"backup": false,
-			"weight": 5,
-			"state": "up",
-			"active": 0,
-			"requests": 667231,
-			"header_time": 20,
-			"response_time": 36,
-			"responses": {
-				"1xx": 0,
-				"2xx": 666310,
-				"3xx": 0,
-				"4xx": 915,
-				"5xx": 6,
-				"total": 667231
-			},
-			"sent": 251946292,
-			"received": 19222475454,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26214,
-				"fails": 0,
-				"unhealthy": 0,
-				"last_passed": true
-			},
-			"downtime": 0,
-			"downstart": {},
-			"selected": {}
+		"peers": {
+			"10.0.0.2:8088": {
+				"server": "hg.example.internal",
+				"service": "_hg._tcp",
+				"backup": false,
+				"weight": 1,
+				"state": "up",
+				"selected": {
+					"current": 0,
+					"total": 1234
+				},
+				"responses": {
+					"200": 1200,
+					"304": 34
+				},
+				"data": {
+					"sent": 91234,
+					"received": 4567890
+				},
+				"health": {
+					"fails": 2,
+					"unavailable": 0,
+					"downtime": 0
+				},
+				"sid": "9b0e1c5d3f1c2a4e8d7b6a5f4e3d2c1b"
+			}
 		},
-		{
-			"id": 1,
-			"server": "10.0.0.1:8089",
-			"name": "10.0.0.1:8089",
-			"backup": true,
-			"weight": 1,
-			"state": "unhealthy",
-			"active": 0,
-			"requests": 0,
-			"responses": {
-				"1xx": 0,
-				"2xx": 0,
-				"3xx": 0,
-				"4xx": 0,
-				"5xx": 0,
-				"total": 0
-			},
-			"sent": 0,
-			"received": 0,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26284,
-				"fails": 26284,
-				"unhealthy": 1,
-				"last_passed": false
-			},
-			"downtime": 262925617,
-			"downstart": {},
-			"selected": {}
-		}
-		],
-		"keepalive": 0,
-		"zombies": 0,
-		"zone": "hg-backend"
+		"keepalive": 0
 	}
 }
 `
@@ -527,112 +530,77 @@ const httpCachesPayload = `
 const streamUpstreamsPayload = `
 {
 	"mysql_backends": {
-		"peers": [
-		{
-			"id": 0,
-			"server": "10.0.0.1:12345",
-			"name": "10.0.0.1:12345",
-			"backup": false,
-			"weight": 5,
-			"state": "up",
-			"active": 0,
-			"max_conns": 30,
-			"connecions": 1231,
-			"sent": 251946292,
-			"received": 19222475454,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26214,
-				"fails": 0,
-				"unhealthy": 0,
-				"last_passed": true
+		"peers": {
+			"10.0.0.1:3306": {
+				"server": "db1.example.internal:3306",
+				"backup": false,
+				"weight": 5,
+				"state": "up",
+				"selected": {
+					"current": 12,
+					"total": 1231,
+					"last": "2025-11-24T22:41:09Z"
+				},
+				"max_conns": 30,
+				"data": {
+					"sent": 251946292,
+					"received": 19222475454
+				},
+				"health": {
+					"fails": 0,
+					"unavailable": 0,
+					"downtime": 0,
+					"connect_time": 1,
+					"first_byte_time": 3,
+					"last_byte_time": 250
+				}
 			},
-			"downtime": 0,
-			"downstart": {},
-			"selected": {}
-		},
-		{
-			"id": 1,
-			"server": "10.0.0.1:12346",
-			"name": "10.0.0.1:12346",
-			"backup": true,
-			"weight": 1,
-			"state": "unhealthy",
-			"active": 0,
-			"max_conns": 30,
-			"connections": 0,
-			"sent": 0,
-			"received": 0,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26284,
-				"fails": 26284,
-				"unhealthy": 1,
-				"last_passed": false
-			},
-			"downtime": 262925617,
-			"downstart": {},
-			"selected": {}
+			"10.0.0.2:3306": {
+				"server": "db2.example.internal:3306",
+				"backup": true,
+				"weight": 1,
+				"state": "unavailable",
+				"selected": {
+					"current": 0,
+					"total": 0
+				},
+				"max_conns": 30,
+				"data": {
+					"sent": 0,
+					"received": 0
+				},
+				"health": {
+					"fails": 3,
+					"unavailable": 1,
+					"downtime": 262925617,
+					"downstart": "2025-11-21T10:02:13.876Z"
+				}
+			}
 		}
-		],
-		"zombies": 0,
-		"zone": "mysql_backends"
 	},
 	"dns": {
-		"peers": [
-		{
-			"id": 0,
-			"server": "10.0.0.1:12347",
-			"name": "10.0.0.1:12347",
-			"backup": false,
-			"weight": 5,
-			"state": "up",
-			"active": 0,
-			"max_conns": 30,
-			"connections": 667231,
-			"sent": 251946292,
-			"received": 19222475454,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26214,
-				"fails": 0,
-				"unhealthy": 0,
-				"last_passed": true
-			},
-			"downtime": 0,
-			"downstart": {},
-			"selected": {}
-		},
-		{
-			"id": 1,
-			"server": "10.0.0.1:12348",
-			"name": "10.0.0.1:12348",
-			"backup": true,
-			"weight": 1,
-			"state": "unhealthy",
-			"active": 0,
-			"connections": 0,
-			"max_conns": 30,
-			"sent": 0,
-			"received": 0,
-			"fails": 0,
-			"unavail": 0,
-			"health_checks": {
-				"checks": 26284,
-				"fails": 26284,
-				"unhealthy": 1,
-				"last_passed": false
-			},
-			"downtime": 262925617,
-			"downstart": {},
-			"selected": {}
+		"peers": {
+			"192.168.1.1:53": {
+				"server": "192.168.1.1:53",
+				"service": "_dns._udp",
+				"backup": false,
+				"weight": 1,
+				"state": "up",
+				"selected": {
+					"current": 31,
+					"total": 70
+				},
+				"data": {
+					"sent": 2035,
+					"received": 5302
+				},
+				"health": {
+					"fails": 0,
+					"unavailable": 0,
+					"downtime": 0
+				}
+			}
 		}
-		],
-		"zombies": 0,
-		"zone": "dns"
 	}
 }
 `
@@ -640,34 +608,140 @@ const streamUpstreamsPayload = `
 const streamServerZonesPayload = `
 {
 	"mysql-frontend": {
-		"processing": 2,
-		"connections": 270925,
+		"ssl": {
+			"handshaked": 270925,
+			"reuses": 1523,
+			"timedout": 0,
+			"failed": 4
+		},
+		"connections": {
+			"total": 270925,
+			"processing": 2,
+			"discarded": 0,
+			"passed": 0
+		},
 		"sessions": {
-			"2xx": 155564,
-			"4xx": 0,
-			"5xx": 0,
-			"total": 270925
+			"success": 270917,
+			"invalid": 0,
+			"forbidden": 6,
+			"internal_error": 0,
+			"bad_gateway": 0,
+			"service_unavailable": 0
 		},
-		"discarded": 0,
-		"received": 28988975,
-		"sent": 3879346317
+		"data": {
+			"received": 28988975,
+			"sent": 3879346317
+		}
 	},
 	"dns": {
-		"processing": 1,
-		"connections": 155569,
+		"connections": {
+			"total": 155569,
+			"processing": 1,
+			"discarded": 0,
+			"passed": 0
+		},
 		"sessions": {
-			"2xx": 155564,
-			"4xx": 0,
-			"5xx": 0,
-			"total": 155569
+			"success": 155564,
+			"invalid": 4,
+			"forbidden": 0,
+			"internal_error": 0,
+			"bad_gateway": 1,
+			"service_unavailable": 0
 		},
-		"discarded": 0,
-		"received": 4200363,
-		"sent": 20489184
+		"data": {
+			"received": 4200363,
+			"sent": 20489184
+		}
 	}
 }
 `
 
+func TestGatherAngieMetricsReload(t *testing.T) {
+	payloads := []string{
+		`{"version": "1.10.2", "address": "127.0.0.1", "generation": 1, "load_time": "2025-11-22T21:27:55.136Z"}`,
+		`{"version": "1.10.2", "address": "127.0.0.1", "generation": 1, "load_time": "2025-11-22T21:27:55.136Z"}`,
+		`{"version": "1.10.2", "address": "127.0.0.1", "generation": 2, "load_time": "2025-11-23T08:02:11.402Z"}`,
+		`{"version": "1.10.2", "address": "127.0.0.1", "generation": 2, "load_time": "2025-11-23T08:02:11.402Z"}`,
+	}
+	expected := []bool{false, false, true, false}
+
+	var payload string
+	ts, n := prepareEndpointFunc(t, angiePath, func() string { return payload })
+	defer ts.Close()
+
+	addr, _, _ := prepareAddr(t, ts)
+
+	for i := range payloads {
+		payload = payloads[i]
+
+		var acc testutil.Accumulator
+		require.NoError(t, n.gatherAngieMetrics(addr, &acc))
+
+		reload, ok := acc.BoolField("angie_api_info", "reload")
+		require.True(t, ok)
+		require.Equal(t, expected[i], reload, "gather %d", i)
+	}
+}
+
+func TestGatherMetricsGeneration(t *testing.T) {
+	ts := prepareEndpoints(t, map[string]string{
+		angiePath:       angiePayload,
+		connectionsPath: connectionsPayload,
+	})
+	defer ts.Close()
+
+	n := &AngieAPI{
+		client: ts.Client(),
+	}
+
+	var acc testutil.Accumulator
+	addr, host, port := prepareAddr(t, ts)
+
+	n.gatherMetrics(addr, &acc)
+	require.NoError(t, acc.FirstError())
+
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_connections",
+		map[string]interface{}{
+			"accepted":   int64(1234567890000),
+			"dropped":    int64(2345678900000),
+			"active":     int64(345),
+			"idle":       int64(567),
+			"generation": int64(3),
+		},
+		map[string]string{
+			"source": host,
+			"port":   port,
+		})
+}
+
+func TestGatherDuplicateURLs(t *testing.T) {
+	ts := prepareEndpoints(t, map[string]string{
+		angiePath:       angiePayload,
+		connectionsPath: connectionsPayload,
+	})
+	defer ts.Close()
+
+	// Both URLs share the state of one API, which is gathered only once
+	n := &AngieAPI{
+		Urls: []string{ts.URL + "/api", ts.URL + "/api"},
+		Log:  testutil.Logger{},
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	var count int
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "angie_api_connections" {
+			count++
+		}
+	}
+	require.Equal(t, 1, count)
+}
+
 func TestGatherProcessesMetrics(t *testing.T) {
 	ts, n := prepareEndpoint(t, processesPath, processesPayload)
 	defer ts.Close()
@@ -675,7 +749,7 @@ func TestGatherProcessesMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherProcessesMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, processesPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
@@ -689,6 +763,58 @@ func TestGatherProcessesMetrics(t *testing.T) {
 		})
 }
 
+func TestGatherAngieMetrics(t *testing.T) {
+	ts, n := prepareEndpoint(t, angiePath, angiePayload)
+	defer ts.Close()
+
+	var acc testutil.Accumulator
+	addr, host, port := prepareAddr(t, ts)
+
+	require.NoError(t, n.gatherAngieMetrics(addr, &acc))
+
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_info",
+		map[string]interface{}{
+			"version":      "1.10.2",
+			"build":        "PRO",
+			"address":      "192.168.16.5",
+			"generation":   int64(3),
+			"load_time":    "2025-11-22T21:27:55.136Z",
+			"reload":       false,
+			"config_files": int(2),
+		},
+		map[string]string{
+			"source": host,
+			"port":   port,
+		})
+}
+
+func TestGatherAngieMetricsWithoutOptionalFields(t *testing.T) {
+	ts, n := prepareEndpoint(t, angiePath, `{"version": "1.10.2", "address": "127.0.0.1", "generation": 1, "load_time": "2025-11-22T21:27:55.136Z"}`)
+	defer ts.Close()
+
+	var acc testutil.Accumulator
+	addr, host, port := prepareAddr(t, ts)
+
+	require.NoError(t, n.gatherAngieMetrics(addr, &acc))
+
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_info",
+		map[string]interface{}{
+			"version":    "1.10.2",
+			"address":    "127.0.0.1",
+			"generation": int64(1),
+			"load_time":  "2025-11-22T21:27:55.136Z",
+			"reload":     false,
+		},
+		map[string]string{
+			"source": host,
+			"port":   port,
+		})
+}
+
 func TestGatherConnectionsMetrics(t *testing.T) {
 	ts, n := prepareEndpoint(t, connectionsPath, connectionsPayload)
 	defer ts.Close()
@@ -696,7 +822,7 @@ func TestGatherConnectionsMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherConnectionsMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, connectionsPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
@@ -720,7 +846,7 @@ func TestGatherSlabsMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherSlabsMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, slabsPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
@@ -808,23 +934,25 @@ func TestGatherHttpServerZonesMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherHTTPServerZonesMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, httpServerZonesPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_http_server_zones",
 		map[string]interface{}{
-			"discarded":       int64(2020),
-			"processing":      int(2),
-			"received":        int64(180157219),
-			"requests":        int64(736395),
-			"responses_1xx":   int64(0),
-			"responses_2xx":   int64(727290),
-			"responses_3xx":   int64(4614),
-			"responses_4xx":   int64(934),
-			"responses_5xx":   int64(1535),
-			"responses_total": int64(734373),
-			"sent":            int64(20183175459),
+			"requests_total":      int64(736395),
+			"requests_processing": int64(2),
+			"requests_discarded":  int64(2020),
+			"received":            int64(180157219),
+			"sent":                int64(20183175459),
+			"responses_200":       int64(727290),
+			"responses_301":       int64(4614),
+			"responses_404":       int64(934),
+			"responses_503":       int64(1535),
+			"ssl_handhaked":       int64(4174),
+			"ssl_reuses":          int64(1250),
+			"ssl_timedout":        int64(3),
+			"ssl_failed":          int64(12),
 		},
 		map[string]string{
 			"source": host,
@@ -836,17 +964,15 @@ func TestGatherHttpServerZonesMetrics(t *testing.T) {
 		t,
 		"angie_api_http_server_zones",
 		map[string]interface{}{
-			"discarded":       int64(20326),
-			"processing":      int(1),
-			"received":        int64(51575327),
-			"requests":        int64(185307),
-			"responses_1xx":   int64(0),
-			"responses_2xx":   int64(112674),
-			"responses_3xx":   int64(45383),
-			"responses_4xx":   int64(2504),
-			"responses_5xx":   int64(4419),
-			"responses_total": int64(164980),
-			"sent":            int64(2983241510),
+			"requests_total":      int64(185307),
+			"requests_processing": int64(1),
+			"requests_discarded":  int64(20326),
+			"received":            int64(51575327),
+			"sent":                int64(2983241510),
+			"responses_200":       int64(112674),
+			"responses_304":       int64(45383),
+			"responses_404":       int64(2504),
+			"responses_502":       int64(4419),
 		},
 		map[string]string{
 			"source": host,
@@ -855,6 +981,164 @@ func TestGatherHttpServerZonesMetrics(t *testing.T) {
 		})
 }
 
+func TestGatherNonStandardResponseCodes(t *testing.T) {
+	zone := `{
+		"requests": {"total": 1490, "processing": 0, "discarded": 0},
+		"responses": {"200": 1000, "207": 20, "226": 1, "418": 3, "444": 400, "451": 2, "499": 64},
+		"data": {"received": 1024, "sent": 2048}
+	}`
+	ts := prepareEndpoints(t, map[string]string{
+		httpServerZonesPath:   fmt.Sprintf(`{"zone": %s}`, zone),
+		httpLocationZonesPath: fmt.Sprintf(`{"zone": %s}`, zone),
+		httpUpstreamsPath: `{
+			"backend": {
+				"peers": {
+					"127.0.0.1:8080": {
+						"server": "127.0.0.1:8080",
+						"backup": false,
+						"weight": 1,
+						"state": "up",
+						"selected": {"current": 0, "total": 508},
+						"responses": {"200": 500, "508": 1, "499": 7},
+						"data": {"sent": 100, "received": 200},
+						"health": {"fails": 0, "unavailable": 0, "downtime": 0},
+						"sid": "e7c2ec8e2b6c0fa6bfa6f3ba2e1a7b1d"
+					}
+				},
+				"keepalive": 0
+			}
+		}`,
+	})
+	defer ts.Close()
+
+	n := &AngieAPI{
+		client: ts.Client(),
+	}
+
+	var acc testutil.Accumulator
+	addr, host, port := prepareAddr(t, ts)
+
+	require.NoError(t, gatherSection(n, addr, httpServerZonesPath, &acc))
+	require.NoError(t, gatherSection(n, addr, httpLocationZonesPath, &acc))
+	require.NoError(t, gatherSection(n, addr, httpUpstreamsPath, &acc))
+
+	zoneFields := map[string]interface{}{
+		"requests_total":      int64(1490),
+		"requests_processing": int64(0),
+		"requests_discarded":  int64(0),
+		"received":            int64(1024),
+		"sent":                int64(2048),
+		"responses_200":       int64(1000),
+		"responses_207":       int64(20),
+		"responses_226":       int64(1),
+		"responses_418":       int64(3),
+		"responses_444":       int64(400),
+		"responses_451":       int64(2),
+		"responses_499":       int64(64),
+	}
+	zoneTags := map[string]string{
+		"source": host,
+		"port":   port,
+		"zone":   "zone",
+	}
+	acc.AssertContainsTaggedFields(t, "angie_api_http_server_zones", zoneFields, zoneTags)
+	acc.AssertContainsTaggedFields(t, "angie_api_http_location_zones", zoneFields, zoneTags)
+
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_http_upstream_peers",
+		map[string]interface{}{
+			"server":             "127.0.0.1:8080",
+			"backup":             false,
+			"weight":             int(1),
+			"state":              "up",
+			"selected_current":   int64(0),
+			"selected_total":     int64(508),
+			"sent":               int64(100),
+			"received":           int64(200),
+			"health_fails":       int64(0),
+			"health_unavailable": int64(0),
+			"health_downtime":    int64(0),
+			"responses_200":      int64(500),
+			"responses_499":      int64(7),
+			"responses_508":      int64(1),
+		},
+		map[string]string{
+			"source":   host,
+			"port":     port,
+			"upstream": "backend",
+			"peer":     "127.0.0.1:8080",
+			"sid":      "e7c2ec8e2b6c0fa6bfa6f3ba2e1a7b1d",
+		})
+}
+
+func TestGatherResponseCodeClasses(t *testing.T) {
+	ts := prepareEndpoints(t, map[string]string{
+		httpServerZonesPath: `{
+			"zone": {
+				"requests": {"total": 1490, "processing": 0, "discarded": 0},
+				"responses": {"101": 5, "200": 1000, "206": 20, "304": 1, "404": 3, "499": 64, "502": 2},
+				"data": {"received": 1024, "sent": 2048}
+			}
+		}`,
+	})
+	defer ts.Close()
+
+	addr, host, port := prepareAddr(t, ts)
+	tags := map[string]string{
+		"source": host,
+		"port":   port,
+		"zone":   "zone",
+	}
+
+	classFields := map[string]interface{}{
+		"requests_total":      int64(1490),
+		"requests_processing": int64(0),
+		"requests_discarded":  int64(0),
+		"received":            int64(1024),
+		"sent":                int64(2048),
+		"responses_1xx":       int64(5),
+		"responses_2xx":       int64(1020),
+		"responses_3xx":       int64(1),
+		"responses_4xx":       int64(67),
+		"responses_5xx":       int64(2),
+		"responses_total":     int64(1095),
+	}
+
+	n := &AngieAPI{
+		ResponseCodes: "class",
+		client:        ts.Client(),
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, gatherSection(n, addr, httpServerZonesPath, &acc))
+	acc.AssertContainsTaggedFields(t, "angie_api_http_server_zones", classFields, tags)
+
+	n = &AngieAPI{
+		ResponseCodes: "both",
+		client:        ts.Client(),
+	}
+	require.NoError(t, n.Init())
+
+	bothFields := map[string]interface{}{
+		"responses_101": int64(5),
+		"responses_200": int64(1000),
+		"responses_206": int64(20),
+		"responses_304": int64(1),
+		"responses_404": int64(3),
+		"responses_499": int64(64),
+		"responses_502": int64(2),
+	}
+	for k, v := range classFields {
+		bothFields[k] = v
+	}
+
+	acc.ClearMetrics()
+	require.NoError(t, gatherSection(n, addr, httpServerZonesPath, &acc))
+	acc.AssertContainsTaggedFields(t, "angie_api_http_server_zones", bothFields, tags)
+}
+
 func TestGatherHttpLimitReqsMetrics(t *testing.T) {
 	ts, n := prepareEndpoint(t, httpLimitReqsPath, httpLimitReqsPayload)
 	defer ts.Close()
@@ -862,17 +1146,17 @@ func TestGatherHttpLimitReqsMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherHTTPLimitReqsMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, httpLimitReqsPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_http_limit_reqs",
 		map[string]interface{}{
-			"passed":           int64(2),
-			"delayed":          int64(9),
-			"rejected":         int64(4),
-			"delayed_dry_run":  int64(100),
-			"rejected_dry_run": int64(330),
+			"passed":    int64(2),
+			"skipped":   int64(0),
+			"delayed":   int64(9),
+			"rejected":  int64(4),
+			"exhausted": int64(0),
 		},
 		map[string]string{
 			"source": host,
@@ -884,11 +1168,11 @@ func TestGatherHttpLimitReqsMetrics(t *testing.T) {
 		t,
 		"angie_api_http_limit_reqs",
 		map[string]interface{}{
-			"passed":           int64(451),
-			"delayed":          int64(10),
-			"rejected":         int64(0),
-			"delayed_dry_run":  int64(10),
-			"rejected_dry_run": int64(32),
+			"passed":    int64(451),
+			"skipped":   int64(3),
+			"delayed":   int64(10),
+			"rejected":  int64(0),
+			"exhausted": int64(1),
 		},
 		map[string]string{
 			"source": host,
@@ -904,22 +1188,21 @@ func TestGatherHttpLocationZonesMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherHTTPLocationZonesMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, httpLocationZonesPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_http_location_zones",
 		map[string]interface{}{
-			"discarded":       int64(2020),
-			"received":        int64(180157219),
-			"requests":        int64(736395),
-			"responses_1xx":   int64(0),
-			"responses_2xx":   int64(727290),
-			"responses_3xx":   int64(4614),
-			"responses_4xx":   int64(934),
-			"responses_5xx":   int64(1535),
-			"responses_total": int64(734373),
-			"sent":            int64(20183175459),
+			"requests_total":      int64(736395),
+			"requests_processing": int64(3),
+			"requests_discarded":  int64(2020),
+			"received":            int64(180157219),
+			"sent":                int64(20183175459),
+			"responses_200":       int64(727290),
+			"responses_302":       int64(4614),
+			"responses_404":       int64(934),
+			"responses_500":       int64(1535),
 		},
 		map[string]string{
 			"source": host,
@@ -931,16 +1214,15 @@ func TestGatherHttpLocationZonesMetrics(t *testing.T) {
 		t,
 		"angie_api_http_location_zones",
 		map[string]interface{}{
-			"discarded":       int64(20326),
-			"received":        int64(51575327),
-			"requests":        int64(185307),
-			"responses_1xx":   int64(0),
-			"responses_2xx":   int64(112674),
-			"responses_3xx":   int64(45383),
-			"responses_4xx":   int64(2504),
-			"responses_5xx":   int64(4419),
-			"responses_total": int64(164980),
-			"sent":            int64(2983241510),
+			"requests_total":      int64(185307),
+			"requests_processing": int64(0),
+			"requests_discarded":  int64(20326),
+			"received":            int64(51575327),
+			"sent":                int64(2983241510),
+			"responses_200":       int64(112674),
+			"responses_304":       int64(45383),
+			"responses_403":       int64(2504),
+			"responses_504":       int64(4419),
 		},
 		map[string]string{
 			"source": host,
@@ -956,14 +1238,18 @@ func TestGatherHttpUpstreamsMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherHTTPUpstreamsMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, httpUpstreamsPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_http_upstreams",
 		map[string]interface{}{
-			"keepalive": int(0),
-			"zombies":   int(0),
+			"keepalive":       int(4),
+			"queue_queued":    int64(20112),
+			"queue_waiting":   int64(1011),
+			"queue_dropped":   int64(6031),
+			"queue_timedout":  int64(560),
+			"queue_overflows": int64(13),
 		},
 		map[string]string{
 			"source":   host,
@@ -976,7 +1262,6 @@ func TestGatherHttpUpstreamsMetrics(t *testing.T) {
 		"angie_api_http_upstreams",
 		map[string]interface{}{
 			"keepalive": int(0),
-			"zombies":   int(0),
 		},
 		map[string]string{
 			"source":   host,
@@ -988,136 +1273,149 @@ func TestGatherHttpUpstreamsMetrics(t *testing.T) {
 		t,
 		"angie_api_http_upstream_peers",
 		map[string]interface{}{
-			"active":                   int(0),
-			"backup":                   false,
-			"downtime":                 int64(0),
-			"fails":                    int64(0),
-			"header_time":              int64(20),
-			"healthchecks_checks":      int64(26214),
-			"healthchecks_fails":       int64(0),
-			"healthchecks_last_passed": true,
-			"healthchecks_unhealthy":   int64(0),
-			"received":                 int64(19222475454),
-			"requests":                 int64(667231),
-			"response_time":            int64(36),
-			"responses_1xx":            int64(0),
-			"responses_2xx":            int64(666310),
-			"responses_3xx":            int64(0),
-			"responses_4xx":            int64(915),
-			"responses_5xx":            int64(6),
-			"responses_total":          int64(667231),
-			"sent":                     int64(251946292),
-			"state":                    "up",
-			"unavail":                  int64(0),
-			"weight":                   int(5),
+			"server":             "10.0.0.1:8088",
+			"backup":             false,
+			"weight":             int(5),
+			"state":              "up",
+			"selected_current":   int64(2),
+			"selected_total":     int64(667231),
+			"selected_last":      "2025-11-22T21:28:00Z",
+			"sent":               int64(251946292),
+			"received":           int64(19222475454),
+			"health_fails":       int64(0),
+			"health_unavailable": int64(0),
+			"health_downtime":    int64(0),
+			"responses_200":      int64(666310),
+			"responses_404":      int64(915),
+			"responses_502":      int64(6),
+			"max_conns":          int(100),
+			"header_time":        int64(20),
+			"response_time":      int64(36),
 		},
 		map[string]string{
-			"source":           host,
-			"port":             port,
-			"upstream":         "trac-backend",
-			"upstream_address": "10.0.0.1:8088",
-			"id":               "0",
+			"source":   host,
+			"port":     port,
+			"upstream": "trac-backend",
+			"peer":     "10.0.0.1:8088",
+			"sid":      "0349acf60535cd8bdf89fb53de0f959e",
 		})
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_http_upstream_peers",
 		map[string]interface{}{
-			"active":                   int(0),
-			"backup":                   true,
-			"downtime":                 int64(262925617),
-			"fails":                    int64(0),
-			"healthchecks_checks":      int64(26284),
-			"healthchecks_fails":       int64(26284),
-			"healthchecks_last_passed": false,
-			"healthchecks_unhealthy":   int64(1),
-			"received":                 int64(0),
-			"requests":                 int64(0),
-			"responses_1xx":            int64(0),
-			"responses_2xx":            int64(0),
-			"responses_3xx":            int64(0),
-			"responses_4xx":            int64(0),
-			"responses_5xx":            int64(0),
-			"responses_total":          int64(0),
-			"sent":                     int64(0),
-			"state":                    "unhealthy",
-			"unavail":                  int64(0),
-			"weight":                   int(1),
+			"server":              "10.0.0.1:8089",
+			"backup":              true,
+			"weight":              int(1),
+			"state":               "unavailable",
+			"selected_current":    int64(0),
+			"selected_total":      int64(0),
+			"sent":                int64(0),
+			"received":            int64(0),
+			"health_fails":        int64(26284),
+			"health_unavailable":  int64(1),
+			"health_downtime":     int64(262925617),
+			"health_downstart":    "2025-11-20T08:12:45.387Z",
+			"health_probes_count": int64(26284),
+			"health_probes_fails": int64(26284),
+			"health_probes_last":  "2025-11-22T21:27:58.103Z",
 		},
 		map[string]string{
-			"source":           host,
-			"port":             port,
-			"upstream":         "trac-backend",
-			"upstream_address": "10.0.0.1:8089",
-			"id":               "1",
+			"source":   host,
+			"port":     port,
+			"upstream": "trac-backend",
+			"peer":     "10.0.0.1:8089",
+			"sid":      "adbdc4c737eef0c63976e2f697c8c8b3",
 		})
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_http_upstream_peers",
 		map[string]interface{}{
-			"active":                   int(0),
-			"backup":                   false,
-			"downtime":                 int64(0),
-			"fails":                    int64(0),
-			"header_time":              int64(20),
-			"healthchecks_checks":      int64(26214),
-			"healthchecks_fails":       int64(0),
-			"healthchecks_last_passed": true,
-			"healthchecks_unhealthy":   int64(0),
-			"received":                 int64(19222475454),
-			"requests":                 int64(667231),
-			"response_time":            int64(36),
-			"responses_1xx":            int64(0),
-			"responses_2xx":            int64(666310),
-			"responses_3xx":            int64(0),
-			"responses_4xx":            int64(915),
-			"responses_5xx":            int64(6),
-			"responses_total":          int64(667231),
-			"sent":                     int64(251946292),
-			"state":                    "up",
-			"unavail":                  int64(0),
-			"weight":                   int(5),
+			"server":             "hg.example.internal",
+			"backup":             false,
+			"weight":             int(1),
+			"state":              "up",
+			"selected_current":   int64(0),
+			"selected_total":     int64(1234),
+			"sent":               int64(91234),
+			"received":           int64(4567890),
+			"health_fails":       int64(2),
+			"health_unavailable": int64(0),
+			"health_downtime":    int64(0),
+			"responses_200":      int64(1200),
+			"responses_304":      int64(34),
+			"service":            "_hg._tcp",
 		},
 		map[string]string{
-			"source":           host,
-			"port":             port,
-			"upstream":         "hg-backend",
-			"upstream_address": "10.0.0.1:8088",
-			"id":               "0",
+			"source":   host,
+			"port":     port,
+			"upstream": "hg-backend",
+			"peer":     "10.0.0.2:8088",
+			"sid":      "9b0e1c5d3f1c2a4e8d7b6a5f4e3d2c1b",
 		})
+}
+
+func TestGatherStreamUpstreamsTimingMetrics(t *testing.T) {
+	ts, n := prepareEndpoint(t, streamUpstreamsPath, `{
+		"dns": {
+			"peers": {
+				"192.168.1.1:53": {
+					"server": "192.168.1.1:53",
+					"backup": false,
+					"weight": 1,
+					"state": "up",
+					"selected": {"current": 1, "total": 70},
+					"data": {"sent": 2035, "received": 5302},
+					"health": {
+						"fails": 0,
+						"unavailable": 0,
+						"downtime": 0,
+						"connect_time": 2,
+						"first_byte_time": 14,
+						"last_byte_time": 41,
+						"probes": {
+							"count": 12,
+							"fails": 1
+						}
+					}
+				}
+			}
+		}
+	}`)
+	defer ts.Close()
+
+	var acc testutil.Accumulator
+	addr, host, port := prepareAddr(t, ts)
+
+	require.NoError(t, gatherSection(n, addr, streamUpstreamsPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
-		"angie_api_http_upstream_peers",
+		"angie_api_stream_upstream_peers",
 		map[string]interface{}{
-			"active":                   int(0),
-			"backup":                   true,
-			"downtime":                 int64(262925617),
-			"fails":                    int64(0),
-			"healthchecks_checks":      int64(26284),
-			"healthchecks_fails":       int64(26284),
-			"healthchecks_last_passed": false,
-			"healthchecks_unhealthy":   int64(1),
-			"received":                 int64(0),
-			"requests":                 int64(0),
-			"responses_1xx":            int64(0),
-			"responses_2xx":            int64(0),
-			"responses_3xx":            int64(0),
-			"responses_4xx":            int64(0),
-			"responses_5xx":            int64(0),
-			"responses_total":          int64(0),
-			"sent":                     int64(0),
-			"state":                    "unhealthy",
-			"unavail":                  int64(0),
-			"weight":                   int(1),
+			"server":              "192.168.1.1:53",
+			"backup":              false,
+			"weight":              int(1),
+			"state":               "up",
+			"selected_current":    int64(1),
+			"selected_total":      int64(70),
+			"sent":                int64(2035),
+			"received":            int64(5302),
+			"health_fails":        int64(0),
+			"health_unavailable":  int64(0),
+			"health_downtime":     int64(0),
+			"connect_time":        int64(2),
+			"first_byte_time":     int64(14),
+			"last_byte_time":      int64(41),
+			"health_probes_count": int64(12),
+			"health_probes_fails": int64(1),
 		},
 		map[string]string{
-			"source":           host,
-			"port":             port,
-			"upstream":         "hg-backend",
-			"upstream_address": "10.0.0.1:8089",
-			"id":               "1",
+			"source":   host,
+			"port":     port,
+			"upstream": "dns",
+			"peer":     "192.168.1.1:53",
 		})
 }
 
@@ -1128,7 +1426,7 @@ func TestGatherHttpCachesMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherHTTPCachesMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, httpCachesPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
@@ -1206,23 +1504,27 @@ func TestGatherResolverZonesMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherResolverZonesMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, resolverZonesPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_resolver_zones",
 		map[string]interface{}{
-			"name":     int64(25460),
-			"srv":      int64(130),
-			"addr":     int64(2580),
-			"noerror":  int64(26499),
-			"formerr":  int64(0),
-			"servfail": int64(3),
-			"nxdomain": int64(0),
-			"notimp":   int64(0),
-			"refused":  int64(0),
-			"timedout": int64(243),
-			"unknown":  int64(478),
+			"queries_name":   int64(25460),
+			"queries_srv":    int64(130),
+			"queries_addr":   int64(2580),
+			"sent_a":         int64(25380),
+			"sent_aaaa":      int64(25380),
+			"sent_srv":       int64(130),
+			"sent_ptr":       int64(2580),
+			"success":        int64(26499),
+			"timedout":       int64(243),
+			"format_error":   int64(0),
+			"server_failure": int64(3),
+			"not_found":      int64(0),
+			"unimplemented":  int64(0),
+			"refused":        int64(0),
+			"other":          int64(478),
 		},
 		map[string]string{
 			"source": host,
@@ -1234,17 +1536,21 @@ func TestGatherResolverZonesMetrics(t *testing.T) {
 		t,
 		"angie_api_resolver_zones",
 		map[string]interface{}{
-			"name":     int64(325460),
-			"srv":      int64(1130),
-			"addr":     int64(12580),
-			"noerror":  int64(226499),
-			"formerr":  int64(0),
-			"servfail": int64(283),
-			"nxdomain": int64(0),
-			"notimp":   int64(0),
-			"refused":  int64(0),
-			"timedout": int64(743),
-			"unknown":  int64(1478),
+			"queries_name":   int64(325460),
+			"queries_srv":    int64(1130),
+			"queries_addr":   int64(12580),
+			"sent_a":         int64(325380),
+			"sent_aaaa":      int64(325380),
+			"sent_srv":       int64(1130),
+			"sent_ptr":       int64(12580),
+			"success":        int64(226499),
+			"timedout":       int64(743),
+			"format_error":   int64(0),
+			"server_failure": int64(283),
+			"not_found":      int64(0),
+			"unimplemented":  int64(0),
+			"refused":        int64(0),
+			"other":          int64(1478),
 		},
 		map[string]string{
 			"source": host,
@@ -1253,147 +1559,92 @@ func TestGatherResolverZonesMetrics(t *testing.T) {
 		})
 }
 
-// func TestGatherStreamUpstreams(t *testing.T) {
-// 	ts, n := prepareEndpoint(t, streamUpstreamsPath, streamUpstreamsPayload)
-// 	defer ts.Close()
-
-// 	var acc testutil.Accumulator
-// 	addr, host, port := prepareAddr(t, ts)
-
-// 	require.NoError(t, n.gatherStreamUpstreamsMetrics(addr, &acc))
-
-// 	acc.AssertContainsTaggedFields(
-// 		t,
-// 		"angie_api_stream_upstreams",
-// 		map[string]interface{}{
-// 			"zombies": int(0),
-// 		},
-// 		map[string]string{
-// 			"source":   host,
-// 			"port":     port,
-// 			"upstream": "mysql_backends",
-// 		})
+func TestGatherStreamUpstreamsMetrics(t *testing.T) {
+	ts, n := prepareEndpoint(t, streamUpstreamsPath, streamUpstreamsPayload)
+	defer ts.Close()
 
-// 	acc.AssertContainsTaggedFields(
-// 		t,
-// 		"angie_api_stream_upstreams",
-// 		map[string]interface{}{
-// 			"zombies": int(0),
-// 		},
-// 		map[string]string{
-// 			"source":   host,
-// 			"port":     port,
-// 			"upstream": "dns",
-// 		})
+	var acc testutil.Accumulator
+	addr, host, port := prepareAddr(t, ts)
 
-// 	acc.AssertContainsTaggedFields(
-// 		t,
-// 		"angie_api_stream_upstream_peers",
-// 		map[string]interface{}{
-// 			"active":                   int(0),
-// 			"backup":                   false,
-// 			"connections":              int64(0),
-// 			"downtime":                 int64(0),
-// 			"fails":                    int64(0),
-// 			"healthchecks_checks":      int64(26214),
-// 			"healthchecks_fails":       int64(0),
-// 			"healthchecks_last_passed": true,
-// 			"healthchecks_unhealthy":   int64(0),
-// 			"received":                 int64(19222475454),
-// 			"sent":                     int64(251946292),
-// 			"state":                    "up",
-// 			"unavail":                  int64(0),
-// 			"weight":                   int(5),
-// 		},
-// 		map[string]string{
-// 			"source":           host,
-// 			"port":             port,
-// 			"upstream":         "mysql_backends",
-// 			"upstream_address": "10.0.0.1:12345",
-// 			"id":               "0",
-// 		})
+	require.NoError(t, gatherSection(n, addr, streamUpstreamsPath, &acc))
 
-// 	acc.AssertContainsTaggedFields(
-// 		t,
-// 		"angie_api_stream_upstream_peers",
-// 		map[string]interface{}{
-// 			"active":                   int(0),
-// 			"backup":                   true,
-// 			"connections":              int64(0),
-// 			"downtime":                 int64(262925617),
-// 			"fails":                    int64(0),
-// 			"healthchecks_checks":      int64(26284),
-// 			"healthchecks_fails":       int64(26284),
-// 			"healthchecks_last_passed": false,
-// 			"healthchecks_unhealthy":   int64(1),
-// 			"received":                 int64(0),
-// 			"sent":                     int64(0),
-// 			"state":                    "unhealthy",
-// 			"unavail":                  int64(0),
-// 			"weight":                   int(1),
-// 		},
-// 		map[string]string{
-// 			"source":           host,
-// 			"port":             port,
-// 			"upstream":         "mysql_backends",
-// 			"upstream_address": "10.0.0.1:12346",
-// 			"id":               "1",
-// 		})
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_stream_upstream_peers",
+		map[string]interface{}{
+			"server":             "db1.example.internal:3306",
+			"backup":             false,
+			"weight":             int(5),
+			"state":              "up",
+			"selected_current":   int64(12),
+			"selected_total":     int64(1231),
+			"selected_last":      "2025-11-24T22:41:09Z",
+			"sent":               int64(251946292),
+			"received":           int64(19222475454),
+			"health_fails":       int64(0),
+			"health_unavailable": int64(0),
+			"health_downtime":    int64(0),
+			"connect_time":       int64(1),
+			"first_byte_time":    int64(3),
+			"last_byte_time":     int64(250),
+			"max_conns":          int(30),
+		},
+		map[string]string{
+			"source":   host,
+			"port":     port,
+			"upstream": "mysql_backends",
+			"peer":     "10.0.0.1:3306",
+		})
 
-// 	acc.AssertContainsTaggedFields(
-// 		t,
-// 		"angie_api_stream_upstream_peers",
-// 		map[string]interface{}{
-// 			"active":                   int(0),
-// 			"backup":                   false,
-// 			"connections":              int64(667231),
-// 			"downtime":                 int64(0),
-// 			"fails":                    int64(0),
-// 			"healthchecks_checks":      int64(26214),
-// 			"healthchecks_fails":       int64(0),
-// 			"healthchecks_last_passed": true,
-// 			"healthchecks_unhealthy":   int64(0),
-// 			"received":                 int64(19222475454),
-// 			"sent":                     int64(251946292),
-// 			"state":                    "up",
-// 			"unavail":                  int64(0),
-// 			"weight":                   int(5),
-// 		},
-// 		map[string]string{
-// 			"source":           host,
-// 			"port":             port,
-// 			"upstream":         "dns",
-// 			"upstream_address": "10.0.0.1:12347",
-// 			"id":               "0",
-// 		})
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_stream_upstream_peers",
+		map[string]interface{}{
+			"server":             "db2.example.internal:3306",
+			"backup":             true,
+			"weight":             int(1),
+			"state":              "unavailable",
+			"selected_current":   int64(0),
+			"selected_total":     int64(0),
+			"sent":               int64(0),
+			"received":           int64(0),
+			"health_fails":       int64(3),
+			"health_unavailable": int64(1),
+			"health_downtime":    int64(262925617),
+			"health_downstart":   "2025-11-21T10:02:13.876Z",
+			"max_conns":          int(30),
+		},
+		map[string]string{
+			"source":   host,
+			"port":     port,
+			"upstream": "mysql_backends",
+			"peer":     "10.0.0.2:3306",
+		})
 
-// 	acc.AssertContainsTaggedFields(
-// 		t,
-// 		"angie_api_stream_upstream_peers",
-// 		map[string]interface{}{
-// 			"active":                   int(0),
-// 			"backup":                   true,
-// 			"connections":              int64(0),
-// 			"downtime":                 int64(262925617),
-// 			"fails":                    int64(0),
-// 			"healthchecks_checks":      int64(26284),
-// 			"healthchecks_fails":       int64(26284),
-// 			"healthchecks_last_passed": false,
-// 			"healthchecks_unhealthy":   int64(1),
-// 			"received":                 int64(0),
-// 			"sent":                     int64(0),
-// 			"state":                    "unhealthy",
-// 			"unavail":                  int64(0),
-// 			"weight":                   int(1),
-// 		},
-// 		map[string]string{
-// 			"source":           host,
-// 			"port":             port,
-// 			"upstream":         "dns",
-// 			"upstream_address": "10.0.0.1:12348",
-// 			"id":               "1",
-// 		})
-// }
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_stream_upstream_peers",
+		map[string]interface{}{
+			"server":             "192.168.1.1:53",
+			"service":            "_dns._udp",
+			"backup":             false,
+			"weight":             int(1),
+			"state":              "up",
+			"selected_current":   int64(31),
+			"selected_total":     int64(70),
+			"sent":               int64(2035),
+			"received":           int64(5302),
+			"health_fails":       int64(0),
+			"health_unavailable": int64(0),
+			"health_downtime":    int64(0),
+		},
+		map[string]string{
+			"source":   host,
+			"port":     port,
+			"upstream": "dns",
+			"peer":     "192.168.1.1:53",
+		})
+}
 
 func TestGatherStreamServerZonesMetrics(t *testing.T) {
 	ts, n := prepareEndpoint(t, streamServerZonesPath, streamServerZonesPayload)
@@ -1402,16 +1653,27 @@ func TestGatherStreamServerZonesMetrics(t *testing.T) {
 	var acc testutil.Accumulator
 	addr, host, port := prepareAddr(t, ts)
 
-	require.NoError(t, n.gatherStreamServerZonesMetrics(addr, &acc))
+	require.NoError(t, gatherSection(n, addr, streamServerZonesPath, &acc))
 
 	acc.AssertContainsTaggedFields(
 		t,
 		"angie_api_stream_server_zones",
 		map[string]interface{}{
-			"connections": int(270925),
-			"processing":  int(2),
-			"received":    int64(28988975),
-			"sent":        int64(3879346317),
+			"connections_total":            int64(270925),
+			"connections_processing":       int64(2),
+			"connections_discarded":        int64(0),
+			"sessions_success":             int64(270917),
+			"sessions_invalid":             int64(0),
+			"sessions_forbidden":           int64(6),
+			"sessions_internal_error":      int64(0),
+			"sessions_bad_gateway":         int64(0),
+			"sessions_service_unavailable": int64(0),
+			"received":                     int64(28988975),
+			"sent":                         int64(3879346317),
+			"ssl_handhaked":                int64(270925),
+			"ssl_reuses":                   int64(1523),
+			"ssl_timedout":                 int64(0),
+			"ssl_failed":                   int64(4),
 		},
 		map[string]string{
 			"source": host,
@@ -1423,10 +1685,17 @@ func TestGatherStreamServerZonesMetrics(t *testing.T) {
 		t,
 		"angie_api_stream_server_zones",
 		map[string]interface{}{
-			"connections": int(155569),
-			"processing":  int(1),
-			"received":    int64(4200363),
-			"sent":        int64(20489184),
+			"connections_total":            int64(155569),
+			"connections_processing":       int64(1),
+			"connections_discarded":        int64(0),
+			"sessions_success":             int64(155564),
+			"sessions_invalid":             int64(4),
+			"sessions_forbidden":           int64(0),
+			"sessions_internal_error":      int64(0),
+			"sessions_bad_gateway":         int64(1),
+			"sessions_service_unavailable": int64(0),
+			"received":                     int64(4200363),
+			"sent":                         int64(20489184),
 		},
 		map[string]string{
 			"source": host,
@@ -1435,6 +1704,210 @@ func TestGatherStreamServerZonesMetrics(t *testing.T) {
 		})
 }
 
+func TestGatherMetricsTreeMode(t *testing.T) {
+	tree := fmt.Sprintf(`{"angie": %s, "connections": %s, "http": {"server_zones": %s}}`,
+		angiePayload, connectionsPayload, httpServerZonesPayload)
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/api/" {
+			w.WriteHeader(http.StatusNotFound)
+			t.Errorf("Unexpected request path %q", r.URL.Path)
+			return
+		}
+
+		w.Header()["Content-Type"] = []string{"application/json"}
+		if _, err := fmt.Fprintln(w, tree); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			t.Error(err)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	n := &AngieAPI{
+		FetchMode: fetchModeTree,
+		client:    ts.Client(),
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	addr, host, port := prepareAddr(t, ts)
+
+	n.gatherMetrics(addr, &acc)
+	require.NoError(t, acc.FirstError())
+	require.Equal(t, 1, requests)
+
+	require.True(t, acc.HasMeasurement("angie_api_info"))
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_connections",
+		map[string]interface{}{
+			"accepted":   int64(1234567890000),
+			"dropped":    int64(2345678900000),
+			"active":     int64(345),
+			"idle":       int64(567),
+			"generation": int64(3),
+		},
+		map[string]string{
+			"source": host,
+			"port":   port,
+		})
+	acc.AssertContainsTaggedFields(
+		t,
+		"angie_api_http_server_zones",
+		map[string]interface{}{
+			"requests_total":      int64(185307),
+			"requests_processing": int64(1),
+			"requests_discarded":  int64(20326),
+			"received":            int64(51575327),
+			"sent":                int64(2983241510),
+			"responses_200":       int64(112674),
+			"responses_304":       int64(45383),
+			"responses_404":       int64(2504),
+			"responses_502":       int64(4419),
+			"generation":          int64(3),
+		},
+		map[string]string{
+			"source": host,
+			"port":   port,
+			"zone":   "site2",
+		})
+	require.False(t, acc.HasMeasurement("angie_api_http_upstreams"))
+}
+
+func TestGatherMetricsDiscovery(t *testing.T) {
+	angie := angiePayload
+	payloads := func() map[string]string {
+		return map[string]string{
+			"":                  fmt.Sprintf(`{"angie": %s, "connections": %s, "http": {"server_zones": %s}}`, angie, connectionsPayload, httpServerZonesPayload),
+			angiePath:           angie,
+			connectionsPath:     connectionsPayload,
+			httpServerZonesPath: httpServerZonesPayload,
+		}
+	}
+
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/")
+		requests = append(requests, path)
+
+		payload, ok := payloads()[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header()["Content-Type"] = []string{"application/json"}
+		if _, err := fmt.Fprintln(w, payload); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			t.Error(err)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Log:    testutil.Logger{},
+		client: ts.Client(),
+	}
+	require.NoError(t, n.Init())
+
+	addr, _, _ := prepareAddr(t, ts)
+
+	// The first gather discovers the available sections
+	var acc testutil.Accumulator
+	n.gatherMetrics(addr, &acc)
+	require.NoError(t, acc.FirstError())
+	require.Equal(t, []string{angiePath, "", connectionsPath, httpServerZonesPath}, requests)
+	require.True(t, acc.HasMeasurement("angie_api_connections"))
+	require.True(t, acc.HasMeasurement("angie_api_http_server_zones"))
+
+	// Later gathers only request the available sections
+	requests = nil
+	n.gatherMetrics(addr, &acc)
+	require.NoError(t, acc.FirstError())
+	require.Equal(t, []string{angiePath, connectionsPath, httpServerZonesPath}, requests)
+
+	// A reload triggers a new discovery
+	angie = strings.Replace(angiePayload, `"generation": 3`, `"generation": 4`, 1)
+	requests = nil
+	n.gatherMetrics(addr, &acc)
+	require.NoError(t, acc.FirstError())
+	require.Equal(t, []string{angiePath, "", connectionsPath, httpServerZonesPath}, requests)
+}
+
+func TestGatherMetricsUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	for _, tt := range []struct {
+		name       string
+		exclude    []string
+		apiVersion int64
+	}{
+		{name: "angie section"},
+		{name: "api version detection", exclude: []string{"angie"}},
+		{name: "discovery", exclude: []string{"angie"}, apiVersion: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &AngieAPI{
+				Urls:            []string{ts.URL + "/api"},
+				APIVersion:      tt.apiVersion,
+				SectionsExclude: tt.exclude,
+				Log:             testutil.Logger{},
+			}
+			require.NoError(t, n.Init())
+
+			// The first failed request skips the other sections
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Len(t, acc.Errors, 1)
+			require.Equal(t, errorClassNetwork, errorClass(acc.Errors[0]))
+		})
+	}
+}
+
+// TestGatherSectionsTestdata gathers every section from the Angie response
+// in testdata/<section>.json and compares the result with the metrics in
+// testdata/<section>.out (without the source and port tags).
+func TestGatherSectionsTestdata(t *testing.T) {
+	gatherers := map[string]func(*AngieAPI, *url.URL, telegraf.Accumulator) error{
+		angiePath: (*AngieAPI).gatherAngieMetrics,
+	}
+	for _, v := range apiVersions {
+		for _, s := range v.sections {
+			gatherers[s.path] = s.gather
+		}
+	}
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+
+	for _, name := range sectionNames() {
+		t.Run(name, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+			require.NoError(t, err)
+
+			expected, err := testutil.ParseMetricsFromFile(filepath.Join("testdata", name+".out"), parser)
+			require.NoError(t, err)
+			require.NotEmpty(t, expected)
+
+			ts, n := prepareEndpoint(t, name, string(payload))
+			defer ts.Close()
+
+			var acc testutil.Accumulator
+			addr, _, _ := prepareAddr(t, ts)
+
+			require.NoError(t, gatherers[name](n, addr, &acc))
+
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(),
+				testutil.IgnoreTags("source", "port"), testutil.IgnoreTime(), testutil.SortMetrics())
+		})
+	}
+}
+
 func TestUnavailableEndpoints(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -1512,6 +1985,16 @@ func TestUnknownContentType(t *testing.T) {
 	require.Error(t, acc.FirstError())
 }
 
+// gatherSection gathers a section of the default API version from the target.
+func gatherSection(n *AngieAPI, addr *url.URL, path string, acc telegraf.Accumulator) error {
+	for _, s := range lookupAPIVersion(defaultAPIVersion).sections {
+		if s.path == path {
+			return s.gather(n, addr, acc)
+		}
+	}
+	return fmt.Errorf("unknown section %q", path)
+}
+
 func prepareAddr(t *testing.T, ts *httptest.Server) (addr *url.URL, host, port string) {
 	t.Helper()
 	addr, err := url.Parse(ts.URL + "/api")
@@ -1533,6 +2016,10 @@ func prepareAddr(t *testing.T, ts *httptest.Server) (addr *url.URL, host, port s
 }
 
 func prepareEndpoint(t *testing.T, path, payload string) (*httptest.Server, *AngieAPI) {
+	return prepareEndpointFunc(t, path, func() string { return payload })
+}
+
+func prepareEndpointFunc(t *testing.T, path string, payloadFunc func() string) (*httptest.Server, *AngieAPI) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fullPath := fmt.Sprintf("/api/%s", path)
 		if r.URL.Path != fullPath {
@@ -1542,7 +2029,7 @@ func prepareEndpoint(t *testing.T, path, payload string) (*httptest.Server, *Ang
 		}
 
 		w.Header()["Content-Type"] = []string{"application/json"}
-		if _, err := fmt.Fprintln(w, payload); err != nil {
+		if _, err := fmt.Fprintln(w, payloadFunc()); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			t.Error(err)
 			return
@@ -1552,12 +2039,30 @@ func prepareEndpoint(t *testing.T, path, payload string) (*httptest.Server, *Ang
 	n := &AngieAPI{
 		Urls:       []string{ts.URL + "/api"},
 		APIVersion: defaultAPIVersion,
+		Log:        testutil.Logger{},
 	}
 
-	client, err := n.createHTTPClient()
+	client, err := n.createHTTPClient(&n.HTTPClientConfig)
 	require.NoError(t, err)
 
 	n.client = client
 
 	return ts, n
 }
+
+func prepareEndpoints(t *testing.T, payloads map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := payloads[strings.TrimPrefix(r.URL.Path, "/api/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header()["Content-Type"] = []string{"application/json"}
+		if _, err := fmt.Fprintln(w, payload); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			t.Error(err)
+			return
+		}
+	}))
+}
@@ -0,0 +1,208 @@
+package angie_api
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// Modes of checking the JSON documents against the decode types
+	schemaCheckOff    = "off"
+	schemaCheckWarn   = "warn"
+	schemaCheckStrict = "strict"
+)
+
+// schemaReport lists the fields of the JSON document of a section that do
+// not match the type it is decoded into. Fields are given by their path in
+// the document, with "*" for the keys of maps like zone and peer names.
+type schemaReport struct {
+	// Fields in the document that are not mapped by the type
+	unknown []string
+	// Fields of the type that are not in the document, except for fields
+	// that may be nil (pointers, maps and slices), which are optional
+	missing []string
+}
+
+func (r *schemaReport) empty() bool {
+	return len(r.unknown) == 0 && len(r.missing) == 0
+}
+
+func (r *schemaReport) String() string {
+	var parts []string
+	if len(r.unknown) > 0 {
+		parts = append(parts, "unknown fields "+strings.Join(r.unknown, ", "))
+	}
+	if len(r.missing) > 0 {
+		parts = append(parts, "missing fields "+strings.Join(r.missing, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// checkSchema compares a JSON document with the type it is decoded into.
+func checkSchema(body []byte, typ reflect.Type) (*schemaReport, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+
+	unknown := make(map[string]bool)
+	missing := make(map[string]bool)
+	walkSchema(doc, typ, "", unknown, missing)
+
+	return &schemaReport{
+		unknown: slices.Sorted(maps.Keys(unknown)),
+		missing: slices.Sorted(maps.Keys(missing)),
+	}, nil
+}
+
+func walkSchema(value interface{}, typ reflect.Type, path string, unknown, missing map[string]bool) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	// Values not matching the kind of the type are left to the decoding,
+	// which fails for them
+	switch typ.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(typ)
+		for key, v := range obj {
+			field, ok := fields[key]
+			if !ok {
+				unknown[joinFieldPath(path, key)] = true
+				continue
+			}
+			walkSchema(v, field.Type, joinFieldPath(path, key), unknown, missing)
+		}
+		for name, field := range fields {
+			if _, ok := obj[name]; !ok && !nillable(field.Type) {
+				missing[joinFieldPath(path, name)] = true
+			}
+		}
+	case reflect.Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, v := range obj {
+			walkSchema(v, typ.Elem(), joinFieldPath(path, "*"), unknown, missing)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for _, v := range items {
+			walkSchema(v, typ.Elem(), path, unknown, missing)
+		}
+	}
+}
+
+func nillable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// jsonFields returns the fields of a struct type by their JSON name,
+// including the fields of embedded structs.
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for _, field := range reflect.VisibleFields(typ) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+			continue
+		case field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct:
+			// The fields of embedded structs are visible fields themselves
+			continue
+		case !field.IsExported():
+			continue
+		case name == "":
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// schemaLog remembers the schema mismatches last logged for each section of
+// a target, so they are only logged again when they change.
+type schemaLog struct {
+	mu       sync.Mutex
+	reported map[string]string
+}
+
+// changed records the mismatches of a section, reporting whether they differ
+// from the previous ones.
+func (l *schemaLog) changed(path, mismatches string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.reported == nil {
+		l.reported = make(map[string]string)
+	}
+	if l.reported[path] == mismatches {
+		return false
+	}
+	l.reported[path] = mismatches
+	return true
+}
+
+// sectionType returns the type the JSON document of the section is decoded
+// into, nil for unknown sections.
+func (n *AngieAPI) sectionType(t *target, path string) reflect.Type {
+	if path == angiePath {
+		return reflect.TypeFor[angie]()
+	}
+	for _, s := range n.targetAPIVersion(t).sections {
+		if s.path == path {
+			return s.typ
+		}
+	}
+	return nil
+}
+
+// checkSectionSchema compares the JSON document of a section with its type.
+// Mismatches are logged with schema_check = "warn", and fail the section
+// with schema_check = "strict".
+func (n *AngieAPI) checkSectionSchema(addr *url.URL, path string, body []byte) error {
+	t := n.target(addr)
+	typ := n.sectionType(t, path)
+	if typ == nil {
+		return nil
+	}
+
+	report, err := checkSchema(body, typ)
+	if err != nil {
+		return decodeError(addr, path, err)
+	}
+	t.scrape.schema(path, report)
+
+	if n.SchemaCheck == schemaCheckStrict && !report.empty() {
+		return newGatherError(errorClassSchema, addr, path, errors.New(report.String()))
+	}
+	if t.schema.changed(path, report.String()) && !report.empty() {
+		n.Log.Warnf("Section %q of %q does not match the expected schema: %s", path, addr.String(), report)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package angie_api
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/melroy89/angie_telegraf_plugin/plugins/inputs/angie_api/angietest"
+)
+
+func TestInvalidSchemaCheck(t *testing.T) {
+	n := &AngieAPI{
+		SchemaCheck: "error",
+	}
+	require.ErrorContains(t, n.Init(), "invalid schema_check")
+}
+
+func TestCheckSchema(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		body    string
+		unknown []string
+		missing []string
+	}{
+		{
+			name: "match",
+			body: `{"backend": {"keepalive": 0, "peers": {"10.0.0.1:80": {
+				"server": "10.0.0.1:80", "backup": false, "weight": 1, "state": "up", "sid": "a1",
+				"selected": {"current": 0, "total": 1}, "data": {"sent": 1, "received": 2},
+				"health": {"fails": 0, "unavailable": 0, "downtime": 0, "header_time": 5}}}}}`,
+		},
+		{
+			name: "unknown fields",
+			body: `{"backend": {"keepalive": 0, "backup_switch": {"active": false}, "peers": {"10.0.0.1:80": {
+				"server": "10.0.0.1:80", "backup": false, "weight": 1, "state": "up", "sid": "a1", "drained": false,
+				"selected": {"current": 0, "total": 1}, "data": {"sent": 1, "received": 2},
+				"health": {"fails": 0, "unavailable": 0, "downtime": 0}}}}}`,
+			unknown: []string{"*.backup_switch", "*.peers.*.drained"},
+		},
+		{
+			name: "missing fields",
+			body: `{"backend": {"peers": {"10.0.0.1:80": {
+				"server": "10.0.0.1:80", "backup": false, "weight": 1, "sid": "a1",
+				"selected": {"current": 0, "total": 1}, "data": {"sent": 1},
+				"health": {"fails": 0, "unavailable": 0, "downtime": 0}}}}}`,
+			missing: []string{"*.keepalive", "*.peers.*.data.received", "*.peers.*.state"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := checkSchema([]byte(tt.body), reflect.TypeFor[httpUpstreams]())
+			require.NoError(t, err)
+			require.Equal(t, tt.unknown, report.unknown)
+			require.Equal(t, tt.missing, report.missing)
+		})
+	}
+}
+
+// TestCheckSchemaTestdata makes sure the types match the Angie responses in
+// testdata, so schema_check = "strict" does not fail on them.
+func TestCheckSchemaTestdata(t *testing.T) {
+	n := &AngieAPI{}
+	for _, name := range sectionNames() {
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+			require.NoError(t, err)
+
+			report, err := checkSchema(body, n.sectionType(&target{}, name))
+			require.NoError(t, err)
+			require.Empty(t, report.unknown)
+			require.Empty(t, report.missing)
+		})
+	}
+}
+
+func TestGatherSchemaCheck(t *testing.T) {
+	ts := prepareEndpoints(t, map[string]string{
+		connectionsPath: `{"accepted": 1, "dropped": 0, "active": 1, "idle": 0, "new_field": 1}`,
+	})
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		mode    string
+		errors  int
+		metrics bool
+	}{
+		{mode: schemaCheckOff, metrics: true},
+		{mode: schemaCheckWarn, metrics: true},
+		{mode: schemaCheckStrict, errors: 1},
+	} {
+		t.Run(tt.mode, func(t *testing.T) {
+			n := &AngieAPI{
+				Urls:            []string{ts.URL + "/api"},
+				APIVersion:      1,
+				SectionsInclude: []string{"connections"},
+				SchemaCheck:     tt.mode,
+				Log:             testutil.Logger{},
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Len(t, acc.Errors, tt.errors)
+			require.Equal(t, tt.metrics, acc.HasMeasurement("angie_api_connections"))
+
+			if tt.mode == schemaCheckOff {
+				require.False(t, acc.HasMeasurement("angie_api_schema"))
+				return
+			}
+			if tt.mode == schemaCheckStrict {
+				require.Equal(t, errorClassSchema, errorClass(acc.Errors[0]))
+			}
+			require.True(t, acc.HasTag("angie_api_schema", "section"))
+			unknown, ok := acc.StringField("angie_api_schema", "unknown_fields")
+			require.True(t, ok)
+			require.Equal(t, "new_field", unknown)
+			count, ok := acc.Int64Field("angie_api_schema", "unknown")
+			require.True(t, ok)
+			require.Equal(t, int64(1), count)
+		})
+	}
+}
+
+func TestGatherSchemaCheckFakeAngie(t *testing.T) {
+	ts := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:        []string{ts.URL + "/status"},
+		SchemaCheck: schemaCheckStrict,
+		Log:         testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+	require.False(t, acc.HasMeasurement("angie_api_schema"))
+}
+
+func TestSchemaLog(t *testing.T) {
+	var l schemaLog
+	require.True(t, l.changed("connections", "unknown fields new_field"))
+	require.False(t, l.changed("connections", "unknown fields new_field"))
+	require.True(t, l.changed("connections", ""))
+	require.True(t, l.changed("connections", "unknown fields new_field"))
+}
@@ -0,0 +1,179 @@
+package angie_api
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// scrape collects statistics about a single gather of a target, which are
+// reported as angie_api_scrape measurement. All methods may be called on a
+// nil scrape, as done when gathering sections outside of gatherMetrics.
+type scrape struct {
+	mu sync.Mutex
+
+	start time.Time
+	// Number of requests answered by the API, with any HTTP status
+	responses int64
+	bytesRead int64
+
+	sectionsOK       int64
+	sectionsFailed   int64
+	sectionsNotFound int64
+	latencies        map[string]time.Duration
+	// Number of errors by their class
+	errors map[string]int64
+	// Schema mismatches by section, only with schema_check enabled
+	schemas map[string]*schemaReport
+}
+
+func newScrape() *scrape {
+	return &scrape{
+		start:     time.Now(),
+		latencies: make(map[string]time.Duration),
+		errors:    make(map[string]int64),
+		schemas:   make(map[string]*schemaReport),
+	}
+}
+
+// responded counts a request answered by the API.
+func (s *scrape) responded() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses++
+}
+
+// read counts the bytes read from a response body.
+func (s *scrape) read(bytes int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesRead += int64(bytes)
+}
+
+// section records the outcome and latency of gathering a section.
+func (s *scrape) section(path string, latency time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		s.sectionsOK++
+	case errors.Is(err, errNotFound):
+		s.sectionsNotFound++
+	default:
+		s.sectionsFailed++
+		s.countError(err)
+	}
+	s.latencies[path] = latency
+}
+
+// notFound counts a section skipped because the discovery did not find it.
+func (s *scrape) notFound() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sectionsNotFound++
+}
+
+// failed counts an error that is not specific to a section, like a failed
+// discovery.
+func (s *scrape) failed(err error) {
+	if s == nil || err == nil || errors.Is(err, errNotFound) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countError(err)
+}
+
+// schema records the schema mismatches of a section.
+func (s *scrape) schema(path string, report *schemaReport) {
+	if s == nil || report.empty() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemas[path] = report
+}
+
+func (s *scrape) countError(err error) {
+	// Requests skipped by an open circuit breaker did not fail themselves
+	if !errors.Is(err, errBreakerOpen) {
+		s.errors[errorClass(err)]++
+	}
+}
+
+func (n *AngieAPI) addScrapeMetrics(addr *url.URL, s *scrape, acc telegraf.Accumulator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The API is up if it answered any request of this gather
+	var up int64
+	if s.responses > 0 {
+		up = 1
+	}
+
+	fields := map[string]interface{}{
+		"up":                 up,
+		"duration_ms":        milliseconds(time.Since(s.start)),
+		"bytes_read":         s.bytesRead,
+		"sections_ok":        s.sectionsOK,
+		"sections_failed":    s.sectionsFailed,
+		"sections_not_found": s.sectionsNotFound,
+	}
+	for _, class := range []string{
+		errorClassNetwork,
+		errorClassHTTPStatus,
+		errorClassContentType,
+		errorClassDecode,
+		errorClassSchema,
+		errorClassOther,
+	} {
+		fields["errors_"+class] = s.errors[class]
+	}
+	for path, latency := range s.latencies {
+		fields["latency_"+strings.ReplaceAll(path, "/", "_")+"_ms"] = milliseconds(latency)
+	}
+
+	acc.AddFields("angie_api_scrape", fields, n.getTags(addr))
+
+	for path, report := range s.schemas {
+		tags := n.getTags(addr)
+		tags["section"] = path
+		acc.AddFields(
+			"angie_api_schema",
+			map[string]interface{}{
+				"unknown":        int64(len(report.unknown)),
+				"unknown_fields": strings.Join(report.unknown, ","),
+				"missing":        int64(len(report.missing)),
+				"missing_fields": strings.Join(report.missing, ","),
+			},
+			tags,
+		)
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,115 @@
+package angie_api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/melroy89/angie_telegraf_plugin/plugins/inputs/angie_api/angietest"
+)
+
+func TestScrapeMetrics(t *testing.T) {
+	ts := prepareEndpoints(t, map[string]string{
+		angiePath:       angiePayload,
+		connectionsPath: connectionsPayload,
+		httpCachesPath:  `{"cache": "not an object"}`,
+	})
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/api"},
+		SectionsInclude: []string{"angie", "connections", "http/caches", "http/upstreams"},
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.ErrorContains(t, acc.FirstError(), "cannot unmarshal")
+
+	_, host, port := prepareAddr(t, ts)
+	var found bool
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() != "angie_api_scrape" {
+			continue
+		}
+		found = true
+		require.Equal(t, map[string]string{"source": host, "port": port}, m.Tags())
+
+		fields := m.Fields()
+		// The discovery fails, so every section is requested
+		require.Equal(t, int64(1), fields["up"])
+		require.Equal(t, int64(2), fields["sections_ok"])
+		require.Equal(t, int64(1), fields["sections_failed"])
+		require.Equal(t, int64(1), fields["sections_not_found"])
+		require.Greater(t, fields["bytes_read"], int64(len(angiePayload)))
+		require.Greater(t, fields["duration_ms"], 0.0)
+		for _, field := range []string{
+			"latency_angie_ms",
+			"latency_connections_ms",
+			"latency_http_caches_ms",
+			"latency_http_upstreams_ms",
+		} {
+			require.Contains(t, fields, field)
+		}
+	}
+	require.True(t, found)
+}
+
+func TestScrapeMetricsDiscovery(t *testing.T) {
+	cfg := angietest.DefaultConfig()
+	cfg.Missing = []string{"http/caches", "stream/upstreams"}
+	ts := httptest.NewServer(angietest.NewServer(cfg))
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/status"},
+		SectionsInclude: []string{"angie", "connections", "http/caches", "stream/upstreams"},
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	// Sections the discovery did not find count as not found on every gather
+	for range 2 {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.NoError(t, acc.FirstError())
+
+		ok, found := acc.Int64Field("angie_api_scrape", "sections_ok")
+		require.True(t, found)
+		require.Equal(t, int64(2), ok)
+		notFound, found := acc.Int64Field("angie_api_scrape", "sections_not_found")
+		require.True(t, found)
+		require.Equal(t, int64(2), notFound)
+	}
+}
+
+func TestScrapeMetricsDown(t *testing.T) {
+	ts := prepareEndpoints(t, nil)
+	ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/api"},
+		SectionsInclude: []string{"angie", "connections"},
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Error(t, acc.FirstError())
+
+	up, ok := acc.Int64Field("angie_api_scrape", "up")
+	require.True(t, ok)
+	require.Zero(t, up)
+	// The other sections are skipped after the angie section failed
+	failed, ok := acc.Int64Field("angie_api_scrape", "sections_failed")
+	require.True(t, ok)
+	require.Equal(t, int64(1), failed)
+	bytesRead, ok := acc.Int64Field("angie_api_scrape", "bytes_read")
+	require.True(t, ok)
+	require.Zero(t, bytesRead)
+}
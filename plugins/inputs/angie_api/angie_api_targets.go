@@ -0,0 +1,305 @@
+package angie_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/toml"
+)
+
+const (
+	// Schemes of DNS based targets, optionally followed by the scheme to
+	// request the instances with, e.g. "dns+srv+https"
+	schemeDNSSRV = "dns+srv"
+	schemeDNSA   = "dns+a"
+)
+
+// fileTarget is an Angie API listed in the targets_file.
+type fileTarget struct {
+	URL    string            `json:"url" toml:"url"`
+	Source string            `json:"source" toml:"source"`
+	Tags   map[string]string `json:"tags" toml:"tags"`
+}
+
+// targetsFile holds the targets last read from the targets_file.
+type targetsFile struct {
+	modTime time.Time
+	size    int64
+	targets []*Target
+}
+
+// loadTargetsFile reads the targets_file again if it changed since it was
+// last read, adding and removing the targets listed in it. On errors the
+// previous targets are kept.
+func (n *AngieAPI) loadTargetsFile() error {
+	info, err := os.Stat(n.TargetsFile)
+	if err != nil {
+		return fmt.Errorf("reading targets file failed: %w", err)
+	}
+	if info.ModTime().Equal(n.file.modTime) && info.Size() == n.file.size {
+		return nil
+	}
+
+	entries, err := readTargetsFile(n.TargetsFile)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// Check all entries before changing any target, so the previous targets
+	// are kept as a whole if the file is invalid
+	targets := make([]*Target, 0, len(entries))
+	keys := make(map[string]*Target, len(entries))
+	for i, e := range entries {
+		if e.URL == "" {
+			return fmt.Errorf("url of target %d in %q missing", i+1, n.TargetsFile)
+		}
+		addr, err := parseAddress(e.URL)
+		if err != nil {
+			return fmt.Errorf("unable to parse address %q in %q: %w", e.URL, n.TargetsFile, err)
+		}
+		key := addr.String()
+
+		// Targets of the plugin configuration take precedence
+		if t, ok := n.targets[key]; ok && !slices.Contains(n.file.targets, t.config) || n.inUrls(addr) {
+			n.Log.Warnf("Target %q in %q is already configured, ignoring it", e.URL, n.TargetsFile)
+			continue
+		}
+		if _, ok := keys[key]; ok {
+			return fmt.Errorf("target %q is listed more than once in %q", e.URL, n.TargetsFile)
+		}
+
+		cfg := &Target{
+			URL:    e.URL,
+			Source: e.Source,
+			Tags:   e.Tags,
+			client: n.client,
+		}
+		keys[key] = cfg
+		targets = append(targets, cfg)
+	}
+
+	for _, cfg := range n.file.targets {
+		addr, err := parseAddress(cfg.URL)
+		if err != nil {
+			continue
+		}
+		if _, ok := keys[addr.String()]; !ok {
+			delete(n.targets, addr.String())
+			n.forgetResolved(addr.String())
+		}
+	}
+	for key, cfg := range keys {
+		// Keep the state of targets that were listed before
+		if t, ok := n.targets[key]; ok {
+			t.config = cfg
+		} else {
+			n.targets[key] = &target{config: cfg}
+		}
+	}
+
+	n.Log.Debugf("Read %d targets from %q", len(targets), n.TargetsFile)
+	n.file = targetsFile{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		targets: targets,
+	}
+
+	return nil
+}
+
+// readTargetsFile decodes the targets_file, which is either a JSON array
+// or a TOML file with a [[targets]] table per target.
+func readTargetsFile(path string) ([]fileTarget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading targets file failed: %w", err)
+	}
+
+	var entries []fileTarget
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(data, &entries)
+	case ".toml":
+		var file struct {
+			Targets []fileTarget `toml:"targets"`
+		}
+		err = toml.Unmarshal(data, &file)
+		entries = file.Targets
+	default:
+		return nil, fmt.Errorf("unknown format of targets file %q, expected a .json or .toml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding targets file %q failed: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+// resolver looks up the instances of DNS based targets. It is implemented by
+// net.Resolver and replaced by a stub in tests.
+type resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// isDNSAddress reports whether the URL names a DNS record to resolve into
+// the instances to gather, like "dns+srv://_angie-api._tcp.example.com/status"
+// or "dns+a+https://angie.example.com:8443/status".
+func isDNSAddress(addr *url.URL) bool {
+	return strings.HasPrefix(addr.Scheme, "dns+")
+}
+
+// splitDNSScheme splits the scheme of a DNS based URL into the scheme of the
+// record type and the scheme to request the instances with, "http" unless
+// given otherwise.
+func splitDNSScheme(scheme string) (record, instance string, err error) {
+	for _, record := range []string{schemeDNSSRV, schemeDNSA} {
+		rest, ok := strings.CutPrefix(scheme, record)
+		if !ok {
+			continue
+		}
+		switch rest {
+		case "", "+http":
+			return record, "http", nil
+		case "+https":
+			return record, "https", nil
+		}
+	}
+	return "", "", fmt.Errorf("unsupported scheme %q, expected %q or %q, optionally followed by %q or %q",
+		scheme, schemeDNSSRV, schemeDNSA, "+http", "+https")
+}
+
+// resolveTargets resolves a DNS based URL into the URLs of its instances.
+// Each instance becomes a target with the settings of the target table the
+// URL was given in, if any, and is tagged with the address it resolved to.
+func (n *AngieAPI) resolveTargets(addr *url.URL) ([]*url.URL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	record, scheme, err := splitDNSScheme(addr.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %q failed: %w", addr.String(), err)
+	}
+
+	var instances []string
+	switch record {
+	case schemeDNSSRV:
+		_, records, err := n.resolver.LookupSRV(ctx, "", "", addr.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("resolving %q failed: %w", addr.String(), err)
+		}
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			instances = append(instances, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+		}
+	case schemeDNSA:
+		hosts, err := n.resolver.LookupHost(ctx, addr.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("resolving %q failed: %w", addr.String(), err)
+		}
+		port := addr.Port()
+		switch {
+		case port != "":
+		case scheme == "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+		for _, host := range hosts {
+			instances = append(instances, net.JoinHostPort(host, port))
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.targets == nil {
+		n.targets = make(map[string]*target)
+	}
+	if n.resolved == nil {
+		n.resolved = make(map[string][]*Target)
+	}
+
+	// Settings of the target table the URL was given in, if any
+	parent := &Target{client: n.client}
+	if t, ok := n.targets[addr.String()]; ok && t.config != nil {
+		parent = t.config
+	}
+	previous := n.resolved[addr.String()]
+
+	addrs := make([]*url.URL, 0, len(instances))
+	targets := make([]*Target, 0, len(instances))
+	keys := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		instanceAddr := &url.URL{Scheme: scheme, Host: instance, Path: addr.Path}
+		key := instanceAddr.String()
+		if keys[key] {
+			continue
+		}
+		if t, ok := n.targets[key]; ok && !slices.Contains(previous, t.config) {
+			n.Log.Warnf("Instance %q of %q is already configured, ignoring it", key, addr.String())
+			continue
+		}
+		keys[key] = true
+
+		// The HTTP client settings are already part of the client
+		cfg := &Target{
+			URL:           key,
+			Source:        parent.Source,
+			SourceFrom:    parent.SourceFrom,
+			Username:      parent.Username,
+			Password:      parent.Password,
+			Tags:          maps.Clone(parent.Tags),
+			client:        parent.client,
+			sectionFilter: parent.sectionFilter,
+		}
+		if cfg.Tags == nil {
+			cfg.Tags = make(map[string]string, 1)
+		}
+		cfg.Tags["resolved_address"] = instance
+		// All instances share the DNS name as source unless configured otherwise
+		if cfg.Source == "" && cfg.SourceFrom == "" && (n.SourceFrom == "" || n.SourceFrom == sourceFromURL) {
+			cfg.Source = addr.Hostname()
+		}
+		targets = append(targets, cfg)
+		addrs = append(addrs, instanceAddr)
+
+		// Keep the state of instances that were resolved before
+		if t, ok := n.targets[key]; ok {
+			t.config = cfg
+		} else {
+			n.targets[key] = &target{config: cfg}
+		}
+	}
+
+	for _, cfg := range previous {
+		if !keys[cfg.URL] {
+			delete(n.targets, cfg.URL)
+		}
+	}
+	n.resolved[addr.String()] = targets
+
+	return addrs, nil
+}
+
+// forgetResolved removes the instances of a DNS based URL that is no longer
+// configured. The caller has to hold the lock.
+func (n *AngieAPI) forgetResolved(key string) {
+	for _, cfg := range n.resolved[key] {
+		delete(n.targets, cfg.URL)
+	}
+	delete(n.resolved, key)
+}
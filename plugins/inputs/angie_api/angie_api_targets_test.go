@@ -0,0 +1,327 @@
+package angie_api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/melroy89/angie_telegraf_plugin/plugins/inputs/angie_api/angietest"
+)
+
+// writeTargetsFile writes the targets file with a modification time that
+// differs from the previous one, so the change is noticed within a test.
+func writeTargetsFile(t *testing.T, path, content string, age time.Duration) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	modTime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func gatherPools(t *testing.T, n *AngieAPI) map[string]string {
+	t.Helper()
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	pools := make(map[string]string)
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "angie_api_connections" {
+			pools[m.Tags()["source"]] = m.Tags()["pool"]
+		}
+	}
+	return pools
+}
+
+func TestTargetsFileJSON(t *testing.T) {
+	first := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer first.Close()
+	second := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer second.Close()
+
+	path := filepath.Join(t.TempDir(), "targets.json")
+	writeTargetsFile(t, path, fmt.Sprintf(`[
+		{"url": %q, "source": "first", "tags": {"pool": "a"}},
+		{"url": %q, "source": "second", "tags": {"pool": "b"}}
+	]`, first.URL+"/status", second.URL+"/status"), time.Hour)
+
+	n := &AngieAPI{
+		TargetsFile: path,
+		Log:         testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, map[string]string{"first": "a", "second": "b"}, gatherPools(t, n))
+
+	// Unchanged files are not read again
+	require.Equal(t, map[string]string{"first": "a", "second": "b"}, gatherPools(t, n))
+
+	// Targets are removed and changed between gathers
+	writeTargetsFile(t, path, fmt.Sprintf(`[
+		{"url": %q, "source": "second", "tags": {"pool": "c"}}
+	]`, second.URL+"/status"), 0)
+	require.Equal(t, map[string]string{"second": "c"}, gatherPools(t, n))
+	require.Len(t, n.targets, 1)
+}
+
+func TestTargetsFileTOML(t *testing.T) {
+	ts := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "targets.toml")
+	writeTargetsFile(t, path, fmt.Sprintf(`
+[[targets]]
+  url = %q
+  source = "pool-member"
+
+  [targets.tags]
+    pool = "a"
+`, ts.URL+"/status"), 0)
+
+	n := &AngieAPI{
+		TargetsFile: path,
+		Log:         testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, map[string]string{"pool-member": "a"}, gatherPools(t, n))
+}
+
+func TestTargetsFileInvalid(t *testing.T) {
+	ts := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "targets.json")
+	writeTargetsFile(t, path, fmt.Sprintf(`[{"url": %q, "source": "angie"}]`, ts.URL+"/status"), time.Hour)
+
+	n := &AngieAPI{
+		TargetsFile: path,
+		Log:         testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, map[string]string{"angie": ""}, gatherPools(t, n))
+
+	// The previous targets are kept when the file cannot be decoded
+	writeTargetsFile(t, path, `[{"url": `, 0)
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.ErrorContains(t, acc.FirstError(), "decoding targets file")
+	require.True(t, acc.HasMeasurement("angie_api_connections"))
+
+	// Invalid entries leave the previous targets untouched, also those
+	// listed before the invalid entry
+	other := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer other.Close()
+	writeTargetsFile(t, path, fmt.Sprintf(`[{"url": %q, "source": "other"}, {"url": ""}]`, other.URL+"/status"), time.Minute)
+
+	acc = testutil.Accumulator{}
+	require.NoError(t, n.Gather(&acc))
+	require.ErrorContains(t, acc.FirstError(), "url of target 2")
+	require.Len(t, n.targets, 1)
+
+	// Once the file is fixed, its targets are gathered
+	writeTargetsFile(t, path, fmt.Sprintf(`[{"url": %q, "source": "other"}]`, other.URL+"/status"), 0)
+	require.Equal(t, map[string]string{"other": ""}, gatherPools(t, n))
+	require.Len(t, n.file.targets, 1)
+
+	n = &AngieAPI{
+		TargetsFile: "targets.yaml",
+	}
+	require.ErrorContains(t, n.Init(), "unknown format of targets file")
+}
+
+// stubResolver answers DNS lookups from static records.
+type stubResolver struct {
+	srv   map[string][]*net.SRV
+	hosts map[string][]string
+}
+
+func (r *stubResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	records, ok := r.srv[name]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, records, nil
+}
+
+func (r *stubResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func srvRecord(t *testing.T, ts *httptest.Server) *net.SRV {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	require.NoError(t, err)
+	p, err := strconv.ParseUint(port, 10, 16)
+	require.NoError(t, err)
+	return &net.SRV{Target: host + ".", Port: uint16(p)}
+}
+
+func gatherResolved(t *testing.T, n *AngieAPI) map[string]string {
+	t.Helper()
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	resolved := make(map[string]string)
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "angie_api_connections" {
+			resolved[m.Tags()["resolved_address"]] = m.Tags()["source"]
+		}
+	}
+	return resolved
+}
+
+func TestGatherDNSSRV(t *testing.T) {
+	first := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer first.Close()
+	second := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer second.Close()
+
+	const name = "_angie-api._tcp.example.internal"
+	resolver := &stubResolver{
+		srv: map[string][]*net.SRV{
+			name: {srvRecord(t, first), srvRecord(t, second)},
+		},
+	}
+
+	n := &AngieAPI{
+		Urls:     []string{"dns+srv://" + name + "/status"},
+		Log:      testutil.Logger{},
+		resolver: resolver,
+	}
+	require.NoError(t, n.Init())
+
+	require.Equal(t, map[string]string{
+		first.Listener.Addr().String():  name,
+		second.Listener.Addr().String(): name,
+	}, gatherResolved(t, n))
+
+	// Instances that disappear are no longer gathered
+	resolver.srv[name] = []*net.SRV{srvRecord(t, second)}
+	require.Equal(t, map[string]string{
+		second.Listener.Addr().String(): name,
+	}, gatherResolved(t, n))
+	require.Len(t, n.targets, 1)
+}
+
+func TestGatherDNSA(t *testing.T) {
+	ts := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer ts.Close()
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	require.NoError(t, err)
+
+	n := &AngieAPI{
+		Targets: []*Target{{
+			URL:    "dns+a://angie.example.internal:" + port + "/status",
+			Source: "pool",
+			Tags:   map[string]string{"dc": "eu"},
+		}},
+		Log: testutil.Logger{},
+		resolver: &stubResolver{
+			hosts: map[string][]string{"angie.example.internal": {"127.0.0.1"}},
+		},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	require.True(t, acc.HasMeasurement("angie_api_connections"))
+	for _, m := range acc.GetTelegrafMetrics() {
+		require.Equal(t, "pool", m.Tags()["source"])
+		require.Equal(t, "eu", m.Tags()["dc"])
+		require.Equal(t, "127.0.0.1:"+port, m.Tags()["resolved_address"])
+	}
+}
+
+func TestGatherDNSNotFound(t *testing.T) {
+	n := &AngieAPI{
+		Urls:     []string{"dns+srv://_angie-api._tcp.example.internal/status"},
+		Log:      testutil.Logger{},
+		resolver: &stubResolver{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.ErrorContains(t, acc.FirstError(), "no such host")
+}
+
+func TestGatherDNSSRVHTTPS(t *testing.T) {
+	ts := httptest.NewTLSServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer ts.Close()
+
+	const name = "_angie-api._tcp.example.internal"
+	cfg := &Target{URL: "dns+srv+https://" + name + "/status"}
+	cfg.InsecureSkipVerify = true
+
+	n := &AngieAPI{
+		Targets: []*Target{cfg},
+		Log:     testutil.Logger{},
+		resolver: &stubResolver{
+			srv: map[string][]*net.SRV{name: {srvRecord(t, ts)}},
+		},
+	}
+	require.NoError(t, n.Init())
+
+	require.Equal(t, map[string]string{ts.Listener.Addr().String(): name}, gatherResolved(t, n))
+	require.Contains(t, n.targets, "https://"+ts.Listener.Addr().String()+"/status")
+}
+
+func TestGatherDNSInvalidScheme(t *testing.T) {
+	n := &AngieAPI{
+		Urls:     []string{"dns+srv+ftp://_angie-api._tcp.example.internal/status"},
+		Log:      testutil.Logger{},
+		resolver: &stubResolver{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.ErrorContains(t, acc.FirstError(), `unsupported scheme "dns+srv+ftp"`)
+}
+
+func TestTargetsFileDNSRemoved(t *testing.T) {
+	ts := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer ts.Close()
+
+	const name = "_angie-api._tcp.example.internal"
+	path := filepath.Join(t.TempDir(), "targets.json")
+	writeTargetsFile(t, path, `[{"url": "dns+srv://`+name+`/status"}]`, time.Hour)
+
+	n := &AngieAPI{
+		TargetsFile: path,
+		Log:         testutil.Logger{},
+		resolver: &stubResolver{
+			srv: map[string][]*net.SRV{name: {srvRecord(t, ts)}},
+		},
+	}
+	require.NoError(t, n.Init())
+	require.Len(t, gatherResolved(t, n), 1)
+	require.Len(t, n.targets, 2)
+
+	// The instances go away together with the DNS based target
+	writeTargetsFile(t, path, `[]`, 0)
+	require.Empty(t, gatherResolved(t, n))
+	require.Empty(t, n.targets)
+	require.Empty(t, n.resolved)
+}
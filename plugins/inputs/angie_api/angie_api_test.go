@@ -0,0 +1,644 @@
+package angie_api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/melroy89/angie_telegraf_plugin/plugins/inputs/angie_api/angietest"
+)
+
+func TestInvalidFetchMode(t *testing.T) {
+	n := &AngieAPI{
+		FetchMode: "all",
+	}
+	require.ErrorContains(t, n.Init(), "invalid fetch_mode")
+}
+
+func TestInvalidResponseCodes(t *testing.T) {
+	n := &AngieAPI{
+		ResponseCodes: "classes",
+	}
+	require.ErrorContains(t, n.Init(), "invalid response_codes")
+}
+
+func TestSectionFilter(t *testing.T) {
+	n := &AngieAPI{
+		SectionsInclude: []string{"http/*", "angie"},
+		SectionsExclude: []string{"http/caches", "http/limit_*"},
+	}
+	require.NoError(t, n.Init())
+
+	require.True(t, n.sectionEnabled(&target{}, angiePath))
+	require.True(t, n.sectionEnabled(&target{}, httpServerZonesPath))
+	require.True(t, n.sectionEnabled(&target{}, httpUpstreamsPath))
+	require.False(t, n.sectionEnabled(&target{}, httpCachesPath))
+	require.False(t, n.sectionEnabled(&target{}, httpLimitReqsPath))
+	require.False(t, n.sectionEnabled(&target{}, httpLimitConnsPath))
+	require.False(t, n.sectionEnabled(&target{}, slabsPath))
+	require.False(t, n.sectionEnabled(&target{}, streamUpstreamsPath))
+}
+
+func TestSectionFilterDefault(t *testing.T) {
+	n := &AngieAPI{}
+	require.NoError(t, n.Init())
+
+	for _, name := range sectionNames() {
+		require.True(t, n.sectionEnabled(&target{}, name), name)
+	}
+}
+
+func TestSectionFilterUnknownSection(t *testing.T) {
+	n := &AngieAPI{
+		SectionsInclude: []string{"http/upstream"},
+	}
+	require.ErrorContains(t, n.Init(), `section pattern "http/upstream" does not match any section`)
+
+	n = &AngieAPI{
+		SectionsExclude: []string{"slab*", "steam/*"},
+	}
+	require.ErrorContains(t, n.Init(), `section pattern "steam/*" does not match any section`)
+}
+
+func TestGatherSectionFilter(t *testing.T) {
+	ts := prepareEndpoints(t, map[string]string{
+		angiePath:           angiePayload,
+		connectionsPath:     connectionsPayload,
+		httpServerZonesPath: httpServerZonesPayload,
+		httpLimitReqsPath:   httpLimitReqsPayload,
+	})
+	defer ts.Close()
+
+	n := &AngieAPI{
+		SectionsInclude: []string{"http/*"},
+		SectionsExclude: []string{"http/limit_reqs"},
+		Log:             testutil.Logger{},
+		client:          ts.Client(),
+	}
+	require.NoError(t, n.Init())
+
+	addr, _, _ := prepareAddr(t, ts)
+
+	var acc testutil.Accumulator
+	n.gatherMetrics(addr, &acc)
+	require.NoError(t, acc.FirstError())
+
+	require.True(t, acc.HasMeasurement("angie_api_http_server_zones"))
+	require.False(t, acc.HasMeasurement("angie_api_info"))
+	require.False(t, acc.HasMeasurement("angie_api_connections"))
+	require.False(t, acc.HasMeasurement("angie_api_http_limit_reqs"))
+}
+
+func TestEntityFilters(t *testing.T) {
+	ts := prepareEndpoints(t, map[string]string{
+		httpServerZonesPath:   httpServerZonesPayload,
+		httpLocationZonesPath: httpLocationZonesPayload,
+		httpUpstreamsPath:     httpUpstreamsPayload,
+		httpLimitReqsPath:     httpLimitReqsPayload,
+	})
+	defer ts.Close()
+
+	n := &AngieAPI{
+		ZoneExclude:     []string{"site2"},
+		UpstreamInclude: []string{"hg-*"},
+		LimitInclude:    []string{"limit_1"},
+		Log:             testutil.Logger{},
+		client:          ts.Client(),
+	}
+	require.NoError(t, n.Init())
+
+	addr, _, _ := prepareAddr(t, ts)
+
+	var acc testutil.Accumulator
+	n.gatherMetrics(addr, &acc)
+	require.NoError(t, acc.FirstError())
+
+	names := func(measurement, tag string) []string {
+		var values []string
+		for _, m := range acc.GetTelegrafMetrics() {
+			if m.Name() == measurement {
+				values = append(values, m.Tags()[tag])
+			}
+		}
+		return values
+	}
+	require.ElementsMatch(t, []string{"site1"}, names("angie_api_http_server_zones", "zone"))
+	require.ElementsMatch(t, []string{"site1"}, names("angie_api_http_location_zones", "zone"))
+	require.ElementsMatch(t, []string{"hg-backend"}, names("angie_api_http_upstreams", "upstream"))
+	require.ElementsMatch(t, []string{"hg-backend"}, names("angie_api_http_upstream_peers", "upstream"))
+	require.ElementsMatch(t, []string{"limit_1"}, names("angie_api_http_limit_reqs", "limit"))
+}
+
+func TestNameFilter(t *testing.T) {
+	f, err := newNameFilter([]string{"hg-*", `re:^backend-\d+$`}, []string{"re:-old$"})
+	require.NoError(t, err)
+
+	for name, expected := range map[string]bool{
+		"hg-backend":     true,
+		"hg-backend-old": false,
+		"backend-12":     true,
+		"backend-a":      false,
+		"frontend-1":     false,
+	} {
+		require.Equal(t, expected, f.Match(name), name)
+	}
+
+	f, err = newNameFilter(nil, []string{"re:-old$"})
+	require.NoError(t, err)
+	require.True(t, f.Match("backend"))
+	require.False(t, f.Match("backend-old"))
+
+	_, err = newNameFilter([]string{"re:("}, nil)
+	require.ErrorContains(t, err, `invalid pattern "re:("`)
+}
+
+func TestGatherFakeAngie(t *testing.T) {
+	config := angietest.DefaultConfig()
+	config.Missing = []string{"stream/upstreams"}
+	server := angietest.NewServer(config)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls: []string{ts.URL + "/status"},
+		Log:  testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	gather := func() *testutil.Accumulator {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		acc.Wait(1)
+		require.NoError(t, acc.FirstError())
+		return &acc
+	}
+	accepted := func(acc *testutil.Accumulator) int64 {
+		value, ok := acc.Int64Field("angie_api_connections", "accepted")
+		require.True(t, ok)
+		return value
+	}
+
+	acc := gather()
+	for _, measurement := range []string{
+		"angie_api_info",
+		"angie_api_connections",
+		"angie_api_http_server_zones",
+		"angie_api_http_upstream_peers",
+		"angie_api_http_caches",
+		"angie_api_stream_server_zones",
+	} {
+		require.True(t, acc.HasMeasurement(measurement), measurement)
+	}
+	require.False(t, acc.HasMeasurement("angie_api_stream_upstream_peers"))
+	require.Zero(t, accepted(acc))
+
+	server.Advance()
+	acc = gather()
+	require.Equal(t, int64(10), accepted(acc))
+	reload, _ := acc.BoolField("angie_api_info", "reload")
+	require.False(t, reload)
+
+	// The section missing before the reload is discovered after it
+	server.SetMissing()
+	server.Reload()
+	acc = gather()
+	require.Zero(t, accepted(acc))
+	reload, _ = acc.BoolField("angie_api_info", "reload")
+	require.True(t, reload)
+	require.True(t, acc.HasMeasurement("angie_api_stream_upstream_peers"))
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+		tags     map[string]string
+	}{
+		{
+			name:     "http",
+			url:      "http://localhost/status",
+			expected: "http://localhost/status",
+			tags:     map[string]string{"source": "localhost", "port": "80"},
+		},
+		{
+			name:     "unix socket",
+			url:      "unix:///run/angie/api.sock:/status",
+			expected: "http+unix:///run/angie/api.sock:/status",
+			tags:     map[string]string{"source": "/run/angie/api.sock"},
+		},
+		{
+			name:     "https unix socket",
+			url:      "https+unix:///run/angie/api.sock:/status",
+			expected: "https+unix:///run/angie/api.sock:/status",
+			tags:     map[string]string{"source": "/run/angie/api.sock"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := parseAddress(tt.url)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, addr.String())
+			require.Equal(t, tt.tags, urlTags(addr))
+		})
+	}
+
+	_, err := parseAddress("unix:///run/angie/api.sock")
+	require.ErrorContains(t, err, "expected a unix socket URL")
+}
+
+func TestGatherUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(angietest.NewServer(angietest.DefaultConfig()))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls: []string{"unix://" + socket + ":/status"},
+		Log:  testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	require.True(t, acc.HasMeasurement("angie_api_info"))
+	require.True(t, acc.HasMeasurement("angie_api_http_upstream_peers"))
+	for _, m := range acc.GetTelegrafMetrics() {
+		require.Equal(t, socket, m.Tags()["source"])
+		require.NotContains(t, m.Tags(), "port")
+	}
+}
+
+func TestGatherDuplicateUnixSocketURLs(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(angietest.NewServer(angietest.DefaultConfig()))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	// All URLs denote the same API, which is gathered only once
+	n := &AngieAPI{
+		Urls: []string{
+			"unix://" + socket + ":/status",
+			"http+unix://" + socket + ":/status",
+		},
+		FetchMode: fetchModeTree,
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	var count int
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "angie_api_connections" {
+			count++
+		}
+	}
+	require.Equal(t, 1, count)
+}
+
+func TestTargetConfig(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(`
+[[inputs.angie_api]]
+  urls = ["http://localhost/status"]
+
+  [[inputs.angie_api.target]]
+    url = "https://edge.example.com/status"
+    username = "telegraf"
+    password = "secret"
+    response_timeout = "10s"
+    tls_cert = "/etc/telegraf/client.pem"
+    tls_key = "/etc/telegraf/client.key"
+    sections_include = ["angie", "http/*"]
+
+    [inputs.angie_api.target.tags]
+      dc = "eu"
+`), config.EmptySourcePath))
+	require.Len(t, c.Inputs, 1)
+
+	n, ok := c.Inputs[0].Input.(*AngieAPI)
+	require.True(t, ok)
+	require.Equal(t, []string{"http://localhost/status"}, n.Urls)
+	require.Len(t, n.Targets, 1)
+
+	target := n.Targets[0]
+	require.Equal(t, "https://edge.example.com/status", target.URL)
+	require.Equal(t, config.Duration(10*time.Second), target.ResponseHeaderTimeout)
+	require.Equal(t, "/etc/telegraf/client.pem", target.TLSCert)
+	require.Equal(t, []string{"angie", "http/*"}, target.SectionsInclude)
+	require.Equal(t, map[string]string{"dc": "eu"}, target.Tags)
+	require.False(t, target.Username.Empty())
+}
+
+func TestTargetInvalid(t *testing.T) {
+	n := &AngieAPI{
+		Targets: []*Target{{SectionsInclude: []string{"angie"}}},
+	}
+	require.ErrorContains(t, n.Init(), "url of target 1 missing")
+
+	n = &AngieAPI{
+		Urls:    []string{"http://localhost/status"},
+		Targets: []*Target{{URL: "http://localhost/status"}},
+	}
+	require.ErrorContains(t, n.Init(), "configured more than once")
+
+	n = &AngieAPI{
+		Urls:    []string{"unix:///run/angie/api.sock:/status"},
+		Targets: []*Target{{URL: "http+unix:///run/angie/api.sock:/status"}},
+	}
+	require.ErrorContains(t, n.Init(), "configured more than once")
+
+	n = &AngieAPI{
+		Targets: []*Target{{URL: "http://localhost/status", SectionsInclude: []string{"http/cache"}}},
+	}
+	require.ErrorContains(t, n.Init(), `section pattern "http/cache" does not match any section`)
+
+	// Invalid HTTP client settings fail the start, not every gather
+	cfg := &Target{URL: "https://localhost/status"}
+	cfg.TLSCert = filepath.Join(t.TempDir(), "missing.pem")
+	cfg.TLSKey = filepath.Join(t.TempDir(), "missing.key")
+	n = &AngieAPI{
+		Targets: []*Target{cfg},
+		Log:     testutil.Logger{},
+	}
+	require.ErrorContains(t, n.Init(), `target "https://localhost/status": creating client failed`)
+}
+
+func TestGatherTargets(t *testing.T) {
+	plain := httptest.NewServer(angietest.NewServer(angietest.DefaultConfig()))
+	defer plain.Close()
+
+	api := angietest.NewServer(angietest.DefaultConfig())
+	protected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "telegraf" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+	defer protected.Close()
+
+	n := &AngieAPI{
+		Urls: []string{plain.URL + "/status"},
+		Targets: []*Target{{
+			URL:             protected.URL + "/status",
+			Username:        config.NewSecret([]byte("telegraf")),
+			Password:        config.NewSecret([]byte("secret")),
+			SectionsInclude: []string{"connections"},
+			Tags:            map[string]string{"dc": "eu", "source": "ignored"},
+		}},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	plainTags := urlTags(&url.URL{Scheme: "http", Host: plain.Listener.Addr().String()})
+	protectedTags := urlTags(&url.URL{Scheme: "http", Host: protected.Listener.Addr().String()})
+	var measurements []string
+	for _, m := range acc.GetTelegrafMetrics() {
+		switch m.Tags()["port"] {
+		case plainTags["port"]:
+			require.NotContains(t, m.Tags(), "dc")
+		case protectedTags["port"]:
+			require.Equal(t, "eu", m.Tags()["dc"])
+			require.Equal(t, protectedTags["source"], m.Tags()["source"])
+			measurements = append(measurements, m.Name())
+		default:
+			require.Failf(t, "unexpected metric", "%v", m)
+		}
+	}
+	require.ElementsMatch(t, []string{"angie_api_connections", "angie_api_scrape"}, measurements)
+	require.True(t, acc.HasMeasurement("angie_api_http_upstream_peers"))
+}
+
+func TestSourceTag(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	angieConfig := angietest.DefaultConfig()
+	angieConfig.Address = "10.0.0.5"
+	ts := httptest.NewServer(angietest.NewServer(angieConfig))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		sourceFrom string
+		exclude    []string
+		target     *Target
+		expected   string
+	}{
+		{
+			name:     "url",
+			expected: urlTags(&url.URL{Scheme: "http", Host: ts.Listener.Addr().String()})["source"],
+		},
+		{
+			name:       "hostname",
+			sourceFrom: "hostname",
+			expected:   hostname,
+		},
+		{
+			name:       "address",
+			sourceFrom: "address",
+			expected:   "10.0.0.5",
+		},
+		{
+			// The address is taken from the API version detection then
+			name:       "address without angie section",
+			sourceFrom: "address",
+			exclude:    []string{"angie"},
+			expected:   "10.0.0.5",
+		},
+		{
+			name:     "target alias",
+			target:   &Target{Source: "web-01"},
+			expected: "web-01",
+		},
+		{
+			name:       "target source_from",
+			sourceFrom: "hostname",
+			target:     &Target{SourceFrom: "address"},
+			expected:   "10.0.0.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &AngieAPI{
+				SourceFrom:      tt.sourceFrom,
+				SectionsExclude: tt.exclude,
+				Log:             testutil.Logger{},
+			}
+			if tt.target != nil {
+				tt.target.URL = ts.URL + "/status"
+				n.Targets = []*Target{tt.target}
+			} else {
+				n.Urls = []string{ts.URL + "/status"}
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.NoError(t, acc.FirstError())
+
+			require.NotEmpty(t, acc.GetTelegrafMetrics())
+			for _, m := range acc.GetTelegrafMetrics() {
+				require.Equal(t, tt.expected, m.Tags()["source"], m.Name())
+			}
+		})
+	}
+}
+
+func TestInvalidSourceFrom(t *testing.T) {
+	n := &AngieAPI{
+		SourceFrom: "dns",
+	}
+	require.ErrorContains(t, n.Init(), "invalid source_from")
+
+	n = &AngieAPI{
+		Targets: []*Target{{URL: "http://localhost/status", SourceFrom: "host"}},
+	}
+	require.ErrorContains(t, n.Init(), "invalid source_from")
+
+	// The address cannot be known without the angie section
+	n = &AngieAPI{
+		Urls:            []string{"http://localhost/status"},
+		SourceFrom:      "address",
+		SectionsExclude: []string{"angie"},
+		APIVersion:      1,
+	}
+	require.ErrorContains(t, n.Init(), `source_from "address" requires the "angie" section or api_version = 0`)
+
+	n = &AngieAPI{
+		APIVersion: 1,
+		Targets: []*Target{{
+			URL:             "http://localhost/status",
+			SourceFrom:      "address",
+			SectionsInclude: []string{"connections"},
+		}},
+		Log: testutil.Logger{},
+	}
+	require.ErrorContains(t, n.Init(), `source_from "address" requires the "angie" section or api_version = 0`)
+
+	// Unless the source is an alias anyway
+	n = &AngieAPI{
+		SourceFrom:      "address",
+		SectionsExclude: []string{"angie"},
+		APIVersion:      1,
+		Targets:         []*Target{{URL: "http://localhost/status", Source: "web-01"}},
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+}
+
+// inflightHandler serves the fake Angie API slowly while recording the
+// highest number of requests served at the same time.
+type inflightHandler struct {
+	handler  http.Handler
+	mu       sync.Mutex
+	inflight int
+	peak     int
+}
+
+func (h *inflightHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.inflight++
+	h.peak = max(h.peak, h.inflight)
+	h.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	h.handler.ServeHTTP(w, r)
+
+	h.mu.Lock()
+	h.inflight--
+	h.mu.Unlock()
+}
+
+func TestGatherMaxConcurrentTargets(t *testing.T) {
+	h := &inflightHandler{handler: angietest.NewServer(angietest.DefaultConfig())}
+
+	n := &AngieAPI{
+		SectionsInclude:      []string{"connections"},
+		MaxConcurrentTargets: 2,
+		Log:                  testutil.Logger{},
+	}
+	for range 6 {
+		ts := httptest.NewServer(h)
+		defer ts.Close()
+		n.Urls = append(n.Urls, ts.URL+"/status")
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	var gathered int
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "angie_api_connections" {
+			gathered++
+		}
+	}
+	require.Equal(t, 6, gathered)
+	require.Equal(t, 2, h.peak)
+}
+
+func TestGatherMaxConcurrentRequestsPerTarget(t *testing.T) {
+	h := &inflightHandler{handler: angietest.NewServer(angietest.DefaultConfig())}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:                           []string{ts.URL + "/status"},
+		SectionsExclude:                []string{"angie"},
+		MaxConcurrentRequestsPerTarget: 4,
+		Log:                            testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+
+	require.True(t, acc.HasMeasurement("angie_api_http_upstream_peers"))
+	require.Equal(t, 4, h.peak)
+}
+
+func TestInvalidConcurrency(t *testing.T) {
+	n := &AngieAPI{
+		MaxConcurrentTargets: -1,
+	}
+	require.ErrorContains(t, n.Init(), "invalid max_concurrent_targets")
+
+	n = &AngieAPI{
+		MaxConcurrentRequestsPerTarget: -1,
+	}
+	require.ErrorContains(t, n.Init(), "invalid max_concurrent_requests_per_target")
+}
@@ -1,5 +1,14 @@
 package angie_api
 
+type angie struct {
+	Version     string            `json:"version"`
+	Build       *string           `json:"build"`
+	Address     string            `json:"address"`
+	Generation  int64             `json:"generation"`
+	LoadTime    string            `json:"load_time"`
+	ConfigFiles map[string]string `json:"config_files"`
+}
+
 type processes struct {
 	Respawned int `json:"respawned"`
 }
@@ -82,71 +91,9 @@ type sessionsStats struct {
 	ServiceUnavailable int64 `json:"service_unavailable"`
 }
 
-type responseStats struct {
-	// Table of response codes (100-599)
-	Response100 *int64 `json:"100"`
-	Response101 *int64 `json:"101"`
-	Response102 *int64 `json:"102"`
-	Response103 *int64 `json:"103"`
-	Response200 *int64 `json:"200"`
-	Response201 *int64 `json:"201"`
-	Response202 *int64 `json:"202"`
-	Response203 *int64 `json:"203"`
-	Response204 *int64 `json:"204"`
-	Response205 *int64 `json:"205"`
-	Response206 *int64 `json:"206"`
-	Response207 *int64 `json:"207"`
-	Response208 *int64 `json:"208"`
-	Response226 *int64 `json:"226"`
-	Response300 *int64 `json:"300"`
-	Response301 *int64 `json:"301"`
-	Response302 *int64 `json:"302"`
-	Response303 *int64 `json:"303"`
-	Response304 *int64 `json:"304"`
-	Response305 *int64 `json:"305"`
-	Response307 *int64 `json:"307"`
-	Response308 *int64 `json:"308"`
-	Response400 *int64 `json:"400"`
-	Response401 *int64 `json:"401"`
-	Response402 *int64 `json:"402"`
-	Response403 *int64 `json:"403"`
-	Response404 *int64 `json:"404"`
-	Response405 *int64 `json:"405"`
-	Response406 *int64 `json:"406"`
-	Response407 *int64 `json:"407"`
-	Response408 *int64 `json:"408"`
-	Response409 *int64 `json:"409"`
-	Response410 *int64 `json:"410"`
-	Response411 *int64 `json:"411"`
-	Response412 *int64 `json:"412"`
-	Response413 *int64 `json:"413"`
-	Response414 *int64 `json:"414"`
-	Response415 *int64 `json:"415"`
-	Response416 *int64 `json:"416"`
-	Response417 *int64 `json:"417"`
-	Response418 *int64 `json:"418"`
-	Response421 *int64 `json:"421"`
-	Response422 *int64 `json:"422"`
-	Response423 *int64 `json:"423"`
-	Response424 *int64 `json:"424"`
-	Response425 *int64 `json:"425"`
-	Response426 *int64 `json:"426"`
-	Response428 *int64 `json:"428"`
-	Response429 *int64 `json:"429"`
-	Response431 *int64 `json:"431"`
-	Response451 *int64 `json:"451"`
-	Response500 *int64 `json:"500"`
-	Response501 *int64 `json:"501"`
-	Response502 *int64 `json:"502"`
-	Response503 *int64 `json:"503"`
-	Response504 *int64 `json:"504"`
-	Response505 *int64 `json:"505"`
-	Response506 *int64 `json:"506"`
-	Response507 *int64 `json:"507"`
-	Response508 *int64 `json:"508"`
-	Response510 *int64 `json:"510"`
-	Response511 *int64 `json:"511"`
-}
+// responseStats maps each HTTP status code (e.g. "200" or "499") that
+// occurred to the number of responses with it.
+type responseStats map[string]int64
 
 type httpServerZones map[string]struct {
 	Ssl       *ssl          `json:"ssl"`
@@ -162,10 +109,34 @@ type httpLocationZones map[string]struct {
 }
 
 type healthStats struct {
-	Fails       int64   `json:"fails"`
-	Unavailable int64   `json:"unavailable"`
-	Downtime    int64   `json:"downtime"`
-	Downstart   *string `json:"downstart"`
+	Fails       int64       `json:"fails"`
+	Unavailable int64       `json:"unavailable"`
+	Downtime    int64       `json:"downtime"`
+	Downstart   *string     `json:"downstart"`
+	Probes      *probeStats `json:"probes"`
+}
+
+// probeStats are only present if active health checks (upstream_probe)
+// are configured
+type probeStats struct {
+	Count int64   `json:"count"`
+	Fails int64   `json:"fails"`
+	Last  *string `json:"last"`
+}
+
+type httpHealthStats struct {
+	healthStats
+	// Average time to receive the response header and the whole response
+	HeaderTime   *int64 `json:"header_time"`
+	ResponseTime *int64 `json:"response_time"`
+}
+
+type streamHealthStats struct {
+	healthStats
+	// Average time to connect, to receive the first and the last byte
+	ConnectTime   *int64 `json:"connect_time"`
+	FirstByteTime *int64 `json:"first_byte_time"`
+	LastByteTime  *int64 `json:"last_byte_time"`
 }
 
 type selected struct {
@@ -181,21 +152,32 @@ type data struct {
 
 type httpUpstreams map[string]struct {
 	Peers map[string]struct {
-		Service   *string       `json:"service"`
-		Backup    bool          `json:"backup"`
-		Weight    int           `json:"weight"`
-		State     string        `json:"state"`
-		Selected  selected      `json:"selected"`
-		MaxConns  *int          `json:"max_conns"`
-		Responses responseStats `json:"responses"`
-		Data      data          `json:"data"`
-		Health    healthStats   `json:"health"`
-		SID       string        `json:"sid"`
+		Server    string          `json:"server"`
+		Service   *string         `json:"service"`
+		Backup    bool            `json:"backup"`
+		Weight    int             `json:"weight"`
+		State     string          `json:"state"`
+		Selected  selected        `json:"selected"`
+		MaxConns  *int            `json:"max_conns"`
+		Responses responseStats   `json:"responses"`
+		Data      data            `json:"data"`
+		Health    httpHealthStats `json:"health"`
+		SID       string          `json:"sid"`
 	} `json:"peers"`
-	Keepalive int `json:"keepalive"`
+	Keepalive int         `json:"keepalive"`
+	Queue     *queueStats `json:"queue"`
 	// backup_switch is also only in Pro version
 }
 
+// queueStats are only present if the queue directive is used in the upstream
+type queueStats struct {
+	Queued    int64 `json:"queued"`
+	Waiting   int64 `json:"waiting"`
+	Dropped   int64 `json:"dropped"`
+	TimedOut  int64 `json:"timedout"`
+	Overflows int64 `json:"overflows"`
+}
+
 type streamServerZones map[string]struct {
 	Ssl         *ssl             `json:"ssl"`
 	Connections connectionsStats `json:"connections"`
@@ -205,15 +187,15 @@ type streamServerZones map[string]struct {
 
 type streamUpstreams map[string]struct {
 	Peers map[string]struct {
-		Server   string      `json:"server"`
-		Service  *string     `json:"service"`
-		Backup   bool        `json:"backup"`
-		Weight   int         `json:"weight"`
-		State    string      `json:"state"`
-		Selected selected    `json:"selected"`
-		MaxConns *int        `json:"max_conns"`
-		Data     data        `json:"data"`
-		Health   healthStats `json:"health"`
+		Server   string            `json:"server"`
+		Service  *string           `json:"service"`
+		Backup   bool              `json:"backup"`
+		Weight   int               `json:"weight"`
+		State    string            `json:"state"`
+		Selected selected          `json:"selected"`
+		MaxConns *int              `json:"max_conns"`
+		Data     data              `json:"data"`
+		Health   streamHealthStats `json:"health"`
 	} `json:"peers"`
 }
 
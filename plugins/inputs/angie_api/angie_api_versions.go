@@ -0,0 +1,120 @@
+package angie_api
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// apiVersion is a version of the Angie API together with the sections it
+// provides.
+type apiVersion struct {
+	version int64
+	// Oldest Angie release serving this API version, e.g. {1, 0, 0} for
+	// Angie 1.0.0
+	minAngie []int
+	sections []section
+}
+
+// apiVersions is the registry of all supported API versions, oldest first.
+// It is filled on init, as the sections refer to it when checking the schema.
+var apiVersions []*apiVersion
+
+func init() {
+	apiVersions = []*apiVersion{
+		{version: 1, minAngie: []int{1, 0, 0}, sections: sectionsV1},
+	}
+}
+
+// lookupAPIVersion returns the registered API version, nil if it is not
+// supported.
+func lookupAPIVersion(version int64) *apiVersion {
+	for _, v := range apiVersions {
+		if v.version == version {
+			return v
+		}
+	}
+	return nil
+}
+
+func checkAPIVersion(version int64) error {
+	if version == 0 || lookupAPIVersion(version) != nil {
+		return nil
+	}
+
+	supported := make([]string, 0, len(apiVersions))
+	for _, v := range apiVersions {
+		supported = append(supported, strconv.FormatInt(v.version, 10))
+	}
+	return fmt.Errorf("unsupported api_version %d, expected 0 (auto-detect) or one of %s",
+		version, strings.Join(supported, ", "))
+}
+
+// targetAPIVersion returns the API version to gather from the target: the
+// configured one or, with api_version = 0, the detected one. Until it is
+// detected, the default version is tried.
+func (n *AngieAPI) targetAPIVersion(t *target) *apiVersion {
+	if n.APIVersion != 0 {
+		return lookupAPIVersion(n.APIVersion)
+	}
+	if t.version != nil {
+		return t.version
+	}
+	return lookupAPIVersion(defaultAPIVersion)
+}
+
+// gatherAPIVersion detects the API version of the target from the angie
+// section, for when that section is not gathered anyway. The address of the
+// Angie instance is taken from it as well, for source_from = "address".
+func (n *AngieAPI) gatherAPIVersion(addr *url.URL, t *target) error {
+	body, err := n.gatherPath(addr, angiePath)
+	if err != nil {
+		return err
+	}
+
+	var info angie
+	if err := decode(addr, angiePath, body, &info); err != nil {
+		return err
+	}
+	t.version = n.detectAPIVersion(addr, info.Version)
+	t.address = info.Address
+
+	return nil
+}
+
+// detectAPIVersion returns the newest API version served by the given Angie
+// release, or the default one if the release is unknown.
+func (n *AngieAPI) detectAPIVersion(addr *url.URL, angieVersion string) *apiVersion {
+	release, err := parseVersion(angieVersion)
+	if err != nil {
+		n.Log.Warnf("Unable to detect the API version of %q, using version %d: %v",
+			addr.String(), defaultAPIVersion, err)
+		return lookupAPIVersion(defaultAPIVersion)
+	}
+
+	for _, v := range slices.Backward(apiVersions) {
+		if slices.Compare(release, v.minAngie) >= 0 {
+			return v
+		}
+	}
+
+	n.Log.Warnf("Angie %s at %q is older than all supported API versions, using version %d",
+		angieVersion, addr.String(), defaultAPIVersion)
+	return lookupAPIVersion(defaultAPIVersion)
+}
+
+// parseVersion splits a release like "1.10.2" into its numbers.
+func parseVersion(version string) ([]int, error) {
+	parts := strings.Split(version, ".")
+	numbers := make([]int, 0, len(parts))
+	for _, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
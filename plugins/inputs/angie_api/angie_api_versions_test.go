@@ -0,0 +1,153 @@
+package angie_api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/melroy89/angie_telegraf_plugin/plugins/inputs/angie_api/angietest"
+)
+
+func TestAPIVersionRegistry(t *testing.T) {
+	require.NotNil(t, lookupAPIVersion(defaultAPIVersion))
+	for _, v := range apiVersions {
+		require.NotEmpty(t, v.minAngie, "api version %d", v.version)
+		for _, s := range v.sections {
+			require.NotNil(t, s.typ, "section %q of api version %d", s.path, v.version)
+		}
+	}
+}
+
+func TestSectionDecodeType(t *testing.T) {
+	var decoded connections
+	s := newSection(connectionsPath, func(_ *AngieAPI, _ *url.URL, c connections, _ telegraf.Accumulator) {
+		decoded = c
+	})
+	require.Equal(t, reflect.TypeFor[connections](), s.typ)
+
+	ts, n := prepareEndpoint(t, connectionsPath, connectionsPayload)
+	defer ts.Close()
+	addr, _, _ := prepareAddr(t, ts)
+
+	var acc testutil.Accumulator
+	require.NoError(t, s.gather(n, addr, &acc))
+	require.Equal(t, connections{Accepted: 1234567890000, Dropped: 2345678900000, Active: 345, Idle: 567}, decoded)
+}
+
+func TestInvalidAPIVersion(t *testing.T) {
+	n := &AngieAPI{
+		APIVersion: 99,
+	}
+	require.ErrorContains(t, n.Init(), "unsupported api_version 99")
+}
+
+func TestDetectAPIVersion(t *testing.T) {
+	n := &AngieAPI{
+		Log: testutil.Logger{},
+	}
+	addr := &url.URL{Scheme: "http", Host: "localhost", Path: "/status"}
+
+	for _, tt := range []struct {
+		angie    string
+		expected int64
+	}{
+		{angie: "1.0.0", expected: 1},
+		{angie: "1.10.2", expected: 1},
+		{angie: "2.0", expected: 1},
+		// Unknown releases fall back to the default version
+		{angie: "0.9.1", expected: defaultAPIVersion},
+		{angie: "1.10.2-pro", expected: defaultAPIVersion},
+		{angie: "", expected: defaultAPIVersion},
+	} {
+		t.Run(tt.angie, func(t *testing.T) {
+			require.Equal(t, tt.expected, n.detectAPIVersion(addr, tt.angie).version)
+		})
+	}
+}
+
+func TestGatherAPIVersionDetection(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		include  []string
+		requests []string
+	}{
+		{
+			name:     "from angie section",
+			include:  []string{"angie", "connections"},
+			requests: []string{"angie", "", "connections"},
+		},
+		{
+			name:     "separately",
+			include:  []string{"connections"},
+			requests: []string{"angie", "", "connections"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := angietest.NewServer(angietest.DefaultConfig())
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+
+			n := &AngieAPI{
+				Urls:            []string{ts.URL + "/status"},
+				SectionsInclude: tt.include,
+				Log:             testutil.Logger{},
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.NoError(t, acc.FirstError())
+			require.True(t, acc.HasMeasurement("angie_api_connections"))
+			require.Equal(t, tt.requests, server.Requests())
+
+			addr, err := url.Parse(ts.URL + "/status")
+			require.NoError(t, err)
+			require.Equal(t, int64(1), n.target(addr).version.version)
+		})
+	}
+}
+
+func TestGatherAPIVersionDetectedOnce(t *testing.T) {
+	server := angietest.NewServer(angietest.DefaultConfig())
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/status"},
+		SectionsInclude: []string{"connections"},
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	for range 2 {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.NoError(t, acc.FirstError())
+	}
+	require.Equal(t, []string{"angie", "", "connections", "connections"}, server.Requests())
+}
+
+func TestGatherAPIVersionConfigured(t *testing.T) {
+	server := angietest.NewServer(angietest.DefaultConfig())
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	n := &AngieAPI{
+		Urls:            []string{ts.URL + "/status"},
+		SectionsInclude: []string{"connections"},
+		APIVersion:      1,
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.NoError(t, acc.FirstError())
+	require.Equal(t, []string{"", "connections"}, server.Requests())
+}
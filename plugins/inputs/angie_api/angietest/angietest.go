@@ -0,0 +1,481 @@
+// Package angietest provides a fake Angie API server serving a stateful
+// status tree, so the plugin can be tested and developed without a real Angie.
+package angietest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes the Angie instance simulated by a Server.
+type Config struct {
+	// Location of the API, default: "/status"
+	Prefix string
+	// Version reported in /status/angie, default: "1.10.2"
+	Version string
+	// Address reported in /status/angie, default: "127.0.0.1"
+	Address string
+
+	ServerZones   []string
+	LocationZones []string
+	Caches        []string
+	LimitReqs     []string
+	LimitConns    []string
+	Resolvers     []string
+	// Upstreams maps the upstream names to the addresses of their peers
+	Upstreams map[string][]string
+
+	StreamServerZones []string
+	StreamLimitConns  []string
+	// StreamUpstreams maps the upstream names to the addresses of their peers
+	StreamUpstreams map[string][]string
+
+	// Sections (API paths like "http/caches") answered with 404 Not Found
+	Missing []string
+}
+
+// DefaultConfig returns a configuration with a few entities of every kind.
+func DefaultConfig() Config {
+	return Config{
+		ServerZones:   []string{"example.com", "api.example.com"},
+		LocationZones: []string{"static", "uploads"},
+		Caches:        []string{"CACHE"},
+		LimitReqs:     []string{"ip", "login"},
+		LimitConns:    []string{"addr"},
+		Resolvers:     []string{"resolver_zone"},
+		Upstreams: map[string][]string{
+			"backend": {"127.0.0.1:8080", "127.0.0.1:8081"},
+			"static":  {"127.0.0.1:3005"},
+		},
+		StreamServerZones: []string{"dns", "mysql"},
+		StreamLimitConns:  []string{"limit_conn_zone"},
+		StreamUpstreams: map[string][]string{
+			"dns":   {"192.168.1.1:53", "8.8.8.8:53"},
+			"mysql": {"10.0.0.1:3306"},
+		},
+	}
+}
+
+// Server is a fake Angie API. Its counters only change when calling Advance
+// and are reset by Reload, like those of a real Angie.
+type Server struct {
+	mu         sync.Mutex
+	config     Config
+	step       int64
+	generation int64
+	loadTime   time.Time
+	requests   []string
+}
+
+// NewServer creates a fake Angie API for the given configuration. Use it
+// as handler, e.g. with httptest.NewServer.
+func NewServer(config Config) *Server {
+	if config.Prefix == "" {
+		config.Prefix = "/status"
+	}
+	if config.Version == "" {
+		config.Version = "1.10.2"
+	}
+	if config.Address == "" {
+		config.Address = "127.0.0.1"
+	}
+
+	return &Server{
+		config:     config,
+		generation: 1,
+		loadTime:   time.Now().UTC(),
+	}
+}
+
+// Advance lets one step of traffic pass, increasing all counters.
+func (s *Server) Advance() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.step++
+}
+
+// Reload simulates a configuration reload, which increases the generation
+// and resets all counters.
+func (s *Server) Reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.loadTime = time.Now().UTC()
+	s.step = 0
+}
+
+// SetMissing replaces the sections answered with 404 Not Found.
+func (s *Server) SetMissing(sections ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config.Missing = sections
+}
+
+// Requests returns the paths requested so far, relative to the API location.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, ok := strings.CutPrefix(r.URL.Path, s.config.Prefix)
+	if !ok || path != "" && !strings.HasPrefix(path, "/") {
+		s.notFound(w, r)
+		return
+	}
+	path = strings.Trim(path, "/")
+	s.requests = append(s.requests, path)
+
+	var node interface{} = s.tree()
+	if path != "" {
+		for _, key := range strings.Split(path, "/") {
+			object, ok := node.(map[string]interface{})
+			if !ok {
+				s.notFound(w, r)
+				return
+			}
+			if node, ok = object[key]; !ok {
+				s.notFound(w, r)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(node); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (*Server) notFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck // the status is already sent
+		"error":       "PathNotFound",
+		"description": fmt.Sprintf("Requested API entity %q doesn't exist.", r.URL.Path),
+	})
+}
+
+// tree builds the complete status tree for the current step, leaving out
+// the missing sections.
+func (s *Server) tree() map[string]interface{} {
+	k := s.step
+
+	tree := map[string]interface{}{
+		"angie": map[string]interface{}{
+			"version":    s.config.Version,
+			"address":    s.config.Address,
+			"generation": s.generation,
+			"load_time":  s.loadTime.Format("2006-01-02T15:04:05.000Z"),
+		},
+		"processes": map[string]interface{}{
+			"respawned": 0,
+		},
+		"connections": map[string]interface{}{
+			"accepted": 10 * k,
+			"dropped":  0,
+			"active":   1 + k%5,
+			"idle":     k % 3,
+		},
+		"slabs":     s.slabs(),
+		"resolvers": s.resolvers(k),
+		"http": map[string]interface{}{
+			"server_zones":   s.zones(s.config.ServerZones, k, true),
+			"location_zones": s.zones(s.config.LocationZones, k, false),
+			"upstreams":      s.upstreams(k),
+			"caches":         s.caches(k),
+			"limit_reqs":     s.limitReqs(k),
+			"limit_conns":    limitConns(s.config.LimitConns, k),
+		},
+		"stream": map[string]interface{}{
+			"server_zones": s.streamZones(k),
+			"upstreams":    s.streamUpstreams(k),
+			"limit_conns":  limitConns(s.config.StreamLimitConns, k),
+		},
+	}
+
+	for _, section := range s.config.Missing {
+		keys := strings.Split(section, "/")
+		parent := tree
+		for _, key := range keys[:len(keys)-1] {
+			if parent, _ = parent[key].(map[string]interface{}); parent == nil {
+				break
+			}
+		}
+		if parent != nil {
+			delete(parent, keys[len(keys)-1])
+		}
+	}
+
+	return tree
+}
+
+func (s *Server) slabs() map[string]interface{} {
+	slabs := make(map[string]interface{})
+	for i, zone := range slices.Concat(s.config.ServerZones, s.config.LimitReqs, s.config.LimitConns) {
+		slabs[zone] = map[string]interface{}{
+			"pages": map[string]interface{}{"used": 2 + i, "free": 250 - i},
+			"slots": map[string]interface{}{
+				"64":  map[string]interface{}{"used": 1, "free": 63, "reqs": 1, "fails": 0},
+				"128": map[string]interface{}{"used": i, "free": 32 - i, "reqs": i, "fails": 0},
+			},
+		}
+	}
+	return slabs
+}
+
+func (s *Server) zones(names []string, k int64, withSSL bool) map[string]interface{} {
+	zones := make(map[string]interface{})
+	for i, name := range names {
+		n := int64(i + 1)
+		zone := map[string]interface{}{
+			"requests": map[string]interface{}{
+				"total":      10 * n * k,
+				"processing": k % 3,
+				"discarded":  k / 10,
+			},
+			"responses": responses(n*k, 7, 1, 1, 1),
+			"data": map[string]interface{}{
+				"received": 300 * n * k,
+				"sent":     4000 * n * k,
+			},
+		}
+		if withSSL && i%2 == 0 {
+			zone["ssl"] = map[string]interface{}{
+				"handshaked": 2 * n * k,
+				"reuses":     n * k,
+				"timedout":   0,
+				"failed":     k / 20,
+			}
+		}
+		zones[name] = zone
+	}
+	return zones
+}
+
+// responses spreads the given number of steps over the status codes 200,
+// 304, 404 and 499 with the given weights.
+func responses(steps, ok, notModified, notFound, closed int64) map[string]interface{} {
+	codes := make(map[string]interface{})
+	if steps == 0 {
+		return codes
+	}
+	codes["200"] = ok * steps
+	codes["304"] = notModified * steps
+	codes["404"] = notFound * steps
+	codes["499"] = closed * steps
+	return codes
+}
+
+func (s *Server) upstreams(k int64) map[string]interface{} {
+	upstreams := make(map[string]interface{})
+	for name, addresses := range s.config.Upstreams {
+		peers := make(map[string]interface{})
+		for j, address := range addresses {
+			n := int64(j + 1)
+			peer := map[string]interface{}{
+				"server":    address,
+				"backup":    j > 0,
+				"weight":    1,
+				"state":     "up",
+				"selected":  s.selected(n*k, k),
+				"responses": responses(n*k, 4, 0, 0, 1),
+				"data": map[string]interface{}{
+					"sent":     200 * n * k,
+					"received": 3000 * n * k,
+				},
+				"health": map[string]interface{}{
+					"fails":       0,
+					"unavailable": 0,
+					"downtime":    0,
+				},
+				"sid": sid(address),
+			}
+			if k > 0 {
+				health := peer["health"].(map[string]interface{})
+				health["header_time"] = 10 + j
+				health["response_time"] = 20 + j
+			}
+			peers[address] = peer
+		}
+		upstreams[name] = map[string]interface{}{
+			"peers":     peers,
+			"keepalive": len(addresses),
+		}
+	}
+	return upstreams
+}
+
+func (s *Server) selected(total, k int64) map[string]interface{} {
+	selected := map[string]interface{}{
+		"current": k % 2,
+		"total":   total,
+	}
+	if total > 0 {
+		last := s.loadTime.Add(time.Duration(k) * time.Second)
+		selected["last"] = last.Format(time.RFC3339)
+	}
+	return selected
+}
+
+// sid returns a stable server ID like the ones Angie derives from the address.
+func sid(address string) string {
+	h := fnv.New128a()
+	h.Write([]byte(address))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (s *Server) caches(k int64) map[string]interface{} {
+	stats := func(responses int64) map[string]interface{} {
+		return map[string]interface{}{"responses": responses, "bytes": 1500 * responses}
+	}
+	writtenStats := func(responses int64) map[string]interface{} {
+		stats := stats(responses)
+		stats["responses_written"] = responses
+		stats["bytes_written"] = 1500 * responses
+		return stats
+	}
+
+	caches := make(map[string]interface{})
+	for _, name := range s.config.Caches {
+		caches[name] = map[string]interface{}{
+			"size":        4096 * k,
+			"max_size":    1073741824,
+			"cold":        k == 0,
+			"hit":         stats(3 * k),
+			"stale":       stats(0),
+			"updating":    stats(0),
+			"revalidated": stats(0),
+			"miss":        writtenStats(k),
+			"expired":     writtenStats(k / 2),
+			"bypass":      writtenStats(0),
+		}
+	}
+	return caches
+}
+
+func (s *Server) limitReqs(k int64) map[string]interface{} {
+	limits := make(map[string]interface{})
+	for _, name := range s.config.LimitReqs {
+		limits[name] = map[string]interface{}{
+			"passed":    10 * k,
+			"skipped":   0,
+			"delayed":   k,
+			"rejected":  k / 5,
+			"exhausted": 0,
+		}
+	}
+	return limits
+}
+
+func limitConns(names []string, k int64) map[string]interface{} {
+	limits := make(map[string]interface{})
+	for _, name := range names {
+		limits[name] = map[string]interface{}{
+			"passed":    5 * k,
+			"skipped":   0,
+			"rejected":  k / 10,
+			"exhausted": 0,
+		}
+	}
+	return limits
+}
+
+func (s *Server) resolvers(k int64) map[string]interface{} {
+	resolvers := make(map[string]interface{})
+	for _, name := range s.config.Resolvers {
+		resolvers[name] = map[string]interface{}{
+			"queries": map[string]interface{}{"name": 3 * k, "srv": 0, "addr": 0},
+			"sent":    map[string]interface{}{"a": 3 * k, "aaaa": 3 * k, "srv": 0, "ptr": 0},
+			"responses": map[string]interface{}{
+				"success":        3 * k,
+				"timedout":       0,
+				"format_error":   0,
+				"server_failure": 0,
+				"not_found":      0,
+				"unimplemented":  0,
+				"refused":        0,
+				"other":          0,
+			},
+		}
+	}
+	return resolvers
+}
+
+func (s *Server) streamZones(k int64) map[string]interface{} {
+	zones := make(map[string]interface{})
+	for i, name := range s.config.StreamServerZones {
+		n := int64(i + 1)
+		zones[name] = map[string]interface{}{
+			"connections": map[string]interface{}{
+				"total":      4 * n * k,
+				"processing": k % 2,
+				"discarded":  0,
+				"passed":     0,
+			},
+			"sessions": map[string]interface{}{
+				"success":             4 * n * k,
+				"invalid":             0,
+				"forbidden":           0,
+				"internal_error":      0,
+				"bad_gateway":         0,
+				"service_unavailable": 0,
+			},
+			"data": map[string]interface{}{
+				"received": 100 * n * k,
+				"sent":     250 * n * k,
+			},
+		}
+	}
+	return zones
+}
+
+func (s *Server) streamUpstreams(k int64) map[string]interface{} {
+	upstreams := make(map[string]interface{})
+	for name, addresses := range s.config.StreamUpstreams {
+		peers := make(map[string]interface{})
+		for j, address := range addresses {
+			n := int64(j + 1)
+			health := map[string]interface{}{
+				"fails":       0,
+				"unavailable": 0,
+				"downtime":    0,
+			}
+			if k > 0 {
+				health["connect_time"] = 1 + j
+				health["first_byte_time"] = 5 + j
+				health["last_byte_time"] = 9 + j
+			}
+			peers[address] = map[string]interface{}{
+				"server":   address,
+				"backup":   false,
+				"weight":   1,
+				"state":    "up",
+				"selected": s.selected(2*n*k, k),
+				"data": map[string]interface{}{
+					"sent":     100 * n * k,
+					"received": 250 * n * k,
+				},
+				"health": health,
+			}
+		}
+		upstreams[name] = map[string]interface{}{
+			"peers": peers,
+		}
+	}
+	return upstreams
+}
@@ -0,0 +1,88 @@
+package angietest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, ts *httptest.Server, path string) (int, map[string]interface{}) {
+	t.Helper()
+
+	resp, err := http.Get(ts.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+func TestServerTree(t *testing.T) {
+	ts := httptest.NewServer(NewServer(DefaultConfig()))
+	defer ts.Close()
+
+	status, root := get(t, ts, "/status/")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, root, "angie")
+	require.Contains(t, root, "http")
+	require.Contains(t, root, "stream")
+
+	status, zones := get(t, ts, "/status/http/server_zones?pretty=off")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, zones, "example.com")
+	require.Contains(t, zones, "api.example.com")
+
+	status, _ = get(t, ts, "/status/http/unknown")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = get(t, ts, "/api/http")
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestServerAdvanceAndReload(t *testing.T) {
+	server := NewServer(DefaultConfig())
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	accepted := func() float64 {
+		_, connections := get(t, ts, "/status/connections")
+		return connections["accepted"].(float64)
+	}
+	generation := func() float64 {
+		_, angie := get(t, ts, "/status/angie")
+		return angie["generation"].(float64)
+	}
+
+	require.Zero(t, accepted())
+	server.Advance()
+	server.Advance()
+	require.Equal(t, float64(20), accepted())
+	require.Equal(t, float64(1), generation())
+
+	server.Reload()
+	require.Zero(t, accepted())
+	require.Equal(t, float64(2), generation())
+}
+
+func TestServerMissing(t *testing.T) {
+	server := NewServer(DefaultConfig())
+	server.SetMissing("stream", "http/caches")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	status, root := get(t, ts, "/status")
+	require.Equal(t, http.StatusOK, status)
+	require.NotContains(t, root, "stream")
+	require.NotContains(t, root["http"], "caches")
+	require.Contains(t, root["http"], "upstreams")
+
+	status, _ = get(t, ts, "/status/stream/upstreams")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = get(t, ts, "/status/http/caches")
+	require.Equal(t, http.StatusNotFound, status)
+
+	require.Equal(t, []string{"", "stream/upstreams", "http/caches"}, server.Requests())
+}